@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// API结构体
+type GenerateLinksRequest struct {
+	OriginalURL string `json:"original_url"`
+}
+
+type GenerateLinksResponse struct {
+	Success     bool   `json:"success"`
+	BrowserLink string `json:"browser_link"`
+	// 最短形式的加速链接，如 /gh/owner/repo@ref/path
+	ShortLink   string `json:"short_link"`
+	WgetCommand string `json:"wget_command"`
+	CurlCommand string `json:"curl_command"`
+	GitCommand  string `json:"git_command"`
+	// 按配置的命令模板生成的其他命令，键为模板名称
+	Commands map[string]string `json:"commands,omitempty"`
+	Source   *SourceURL        `json:"source,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// 批量生成请求：urls数组或按行分隔的text二选一
+type BatchGenerateRequest struct {
+	URLs []string `json:"urls"`
+	Text string   `json:"text"`
+}
+
+// 批量生成中单个链接的结果
+type BatchGenerateItem struct {
+	OriginalURL string `json:"original_url"`
+	GenerateLinksResponse
+}
+
+type BatchGenerateResponse struct {
+	Success   bool                `json:"success"`
+	Total     int                 `json:"total"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+	Results   []BatchGenerateItem `json:"results"`
+	Error     string              `json:"error,omitempty"`
+}
+
+// 文本改写请求
+type RewriteTextRequest struct {
+	Text string `json:"text"`
+}
+
+// 文本改写结果：改写后的文本，以及改写和跳过的URL
+type RewriteTextResponse struct {
+	Success bool         `json:"success"`
+	Text    string       `json:"text"`
+	Changed []URLRewrite `json:"changed"`
+	Skipped []URLRewrite `json:"skipped"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// 批量生成、文本改写等接口的请求体大小上限
+const maxAPIBodyBytes = 4 << 20
+
+// 设置API的通用响应头
+func setAPIHeaders(w http.ResponseWriter, methods string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", methods)
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+}
+
+// API处理函数
+func generateLinksAPI(w http.ResponseWriter, r *http.Request) {
+	setAPIHeaders(w, "POST, OPTIONS")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		response := GenerateLinksResponse{
+			Success: false,
+			Error:   "只支持POST请求",
+		}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	var req GenerateLinksRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response := GenerateLinksResponse{
+			Success: false,
+			Error:   "请求格式错误",
+		}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	json.NewEncoder(w).Encode(generateLinks(r.Context(), requestBaseURL(r), req.OriginalURL))
+}
+
+// 为原始链接生成加速链接和下载命令
+func generateLinks(ctx context.Context, baseURL, originalURL string) GenerateLinksResponse {
+	fail := func(msg string) GenerateLinksResponse {
+		return GenerateLinksResponse{Success: false, Error: msg}
+	}
+
+	originalURL = strings.TrimSpace(originalURL)
+	if originalURL == "" {
+		return fail("原始URL不能为空")
+	}
+
+	if !strings.HasPrefix(originalURL, "http://") && !strings.HasPrefix(originalURL, "https://") {
+		return fail("请输入完整的URL（包含http://或https://）")
+	}
+
+	u, err := url.Parse(originalURL)
+	if err != nil {
+		return fail("URL格式无效")
+	}
+	if !isSupportedDomain(normalizeHost(u.Host)) {
+		return fail("只支持GitHub、GitLab、Hugging Face相关域名")
+	}
+
+	// 解析源链接，其他支持的域名（如CDN）直接生成加速链接
+	src, _ := parseSourceURL(u)
+	if msg := validateGenerateSource(u, src); msg != "" {
+		return fail(msg)
+	}
+
+	// 可选：查询上游ref列表，正确拆分带斜杠的分支名和文件路径
+	if src != nil && config.RefResolver.Enabled {
+		resolveSourceRef(ctx, src)
+	}
+
+	// 生成加速链接
+	acceleratedURL := baseURL + "/" + originalURL
+
+	// 提取文件名
+	fileName := downloadFileName(u, src)
+
+	// 生成各种命令
+	wgetCmd := fmt.Sprintf("wget %s -O %s", shellQuote(acceleratedURL), shellQuote(fileName))
+	curlCmd := fmt.Sprintf("curl -L %s -o %s", shellQuote(acceleratedURL), shellQuote(fileName))
+	vars := commandVars(baseURL, originalURL, acceleratedURL, fileName, src)
+
+	return GenerateLinksResponse{
+		Success:     true,
+		BrowserLink: acceleratedURL,
+		ShortLink:   shortLink(baseURL, originalURL, src),
+		WgetCommand: wgetCmd,
+		CurlCommand: curlCmd,
+		GitCommand:  gitCloneCommand(baseURL, src),
+		Commands:    renderCommands(config.CommandTemplates, vars),
+		Source:      src,
+	}
+}
+
+// 检查生成链接时支持的链接类型，返回错误信息
+func validateGenerateSource(u *url.URL, src *SourceURL) string {
+	switch normalizeHost(u.Host) {
+	case "huggingface.co", "hf.co":
+		// Hugging Face 仅支持文件下载
+		if src == nil || src.Kind != SourceFile {
+			return "Hugging Face 链接需要包含具体文件路径（/blob/ 或 /resolve/）"
+		}
+	case "github.com":
+		// GitHub 仅支持文件下载和git clone
+		if src == nil {
+			return "GitHub 链接仅支持仓库根路径（git clone）或文件路径（/blob/, /raw/, /tree/）"
+		}
+	case "gitlab.com":
+		// GitLab 仅支持文件下载和git clone
+		if src == nil {
+			return "GitLab 链接仅支持仓库根路径（git clone）或文件路径（/-/blob/, /-/raw/, /-/tree/）"
+		}
+	}
+	return ""
+}
+
+// 可以git clone的仓库地址，仅支持GitHub/GitLab的仓库、文件和目录链接
+func gitCloneURL(src *SourceURL) string {
+	if src == nil || (src.Platform != platformGitHub && src.Platform != platformGitLab) {
+		return ""
+	}
+	switch src.Kind {
+	case SourceRepo, SourceFile, SourceTree:
+		return src.CloneURL()
+	}
+	return ""
+}
+
+// 生成git clone命令
+func gitCloneCommand(baseURL string, src *SourceURL) string {
+	if src == nil || (src.Platform != platformGitHub && src.Platform != platformGitLab) {
+		return "此链接不支持 git clone（仅支持 GitHub/GitLab 仓库）"
+	}
+
+	// 检查是否是不支持git clone的链接类型
+	if gitCloneURL(src) == "" {
+		return "此链接不支持 git clone（archive/release/raw文件请使用浏览器或下载命令）"
+	}
+
+	cloneURL := shellQuote(baseURL + "/" + src.CloneURL())
+	// ref已确认时克隆对应的分支或标签
+	if src.RefResolved && src.Kind != SourceRepo && !isCommitSHA(src.Ref) {
+		return fmt.Sprintf("git clone -b %s %s", shellQuote(src.Ref), cloneURL)
+	}
+	return "git clone " + cloneURL
+}
+
+// 批量生成接口：JSON（{"urls": [...]} 或 {"text": "..."}）或纯文本（每行一个链接）
+func batchGenerateLinksAPI(w http.ResponseWriter, r *http.Request) {
+	setAPIHeaders(w, "POST, OPTIONS")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	fail := func(status int, response BatchGenerateResponse) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+	}
+	if r.Method != "POST" {
+		fail(http.StatusMethodNotAllowed, BatchGenerateResponse{Success: false, Error: "只支持POST请求"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxAPIBodyBytes))
+	if err != nil {
+		fail(http.StatusRequestEntityTooLarge, BatchGenerateResponse{Success: false, Error: "请求体过大或读取失败"})
+		return
+	}
+
+	var urls []string
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		var req BatchGenerateRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			fail(http.StatusBadRequest, BatchGenerateResponse{Success: false, Error: "请求格式错误"})
+			return
+		}
+		urls = append(req.URLs, splitURLLines(req.Text)...)
+	} else {
+		urls = splitURLLines(string(body))
+	}
+
+	if len(urls) == 0 {
+		fail(http.StatusBadRequest, BatchGenerateResponse{Success: false, Error: "原始URL不能为空"})
+		return
+	}
+	if len(urls) > config.API.MaxBatchSize {
+		fail(http.StatusRequestEntityTooLarge, BatchGenerateResponse{
+			Success: false,
+			Total:   len(urls),
+			Error:   fmt.Sprintf("单次最多处理 %d 个链接", config.API.MaxBatchSize),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(batchGenerateLinks(r.Context(), requestBaseURL(r), urls))
+}
+
+// 按行拆分链接，忽略空行和 # 开头的注释行
+func splitURLLines(text string) []string {
+	var urls []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	return urls
+}
+
+// 并发生成多个链接，结果顺序与输入一致
+func batchGenerateLinks(ctx context.Context, baseURL string, urls []string) BatchGenerateResponse {
+	results := make([]BatchGenerateItem, len(urls))
+
+	sem := make(chan struct{}, config.API.BatchConcurrency)
+	var wg sync.WaitGroup
+	for i, originalURL := range urls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, originalURL string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = BatchGenerateItem{
+				OriginalURL:           originalURL,
+				GenerateLinksResponse: generateLinks(ctx, baseURL, originalURL),
+			}
+		}(i, originalURL)
+	}
+	wg.Wait()
+
+	response := BatchGenerateResponse{Success: true, Total: len(results), Results: results}
+	for _, item := range results {
+		if item.Success {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+	return response
+}
+
+// 文本改写接口：把README、Dockerfile、脚本等文本中支持域名的URL改写为经过代理的链接
+// 请求体为JSON（{"text": "..."}）或纯文本；?format=text 时直接返回改写后的文本
+func rewriteTextAPI(w http.ResponseWriter, r *http.Request) {
+	setAPIHeaders(w, "POST, OPTIONS")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		json.NewEncoder(w).Encode(RewriteTextResponse{Success: false, Error: "只支持POST请求"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxAPIBodyBytes))
+	if err != nil {
+		json.NewEncoder(w).Encode(RewriteTextResponse{Success: false, Error: "请求体过大或读取失败"})
+		return
+	}
+
+	text := string(body)
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		var req RewriteTextRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			json.NewEncoder(w).Encode(RewriteTextResponse{Success: false, Error: "请求格式错误"})
+			return
+		}
+		text = req.Text
+	}
+
+	response := RewriteTextResponse{Success: true, Changed: []URLRewrite{}, Skipped: []URLRewrite{}}
+	rewritten := rewriteTextURLs([]byte(text), requestBaseURL(r), func(rw URLRewrite, changed bool) {
+		if changed {
+			response.Changed = append(response.Changed, rw)
+		} else {
+			response.Skipped = append(response.Skipped, rw)
+		}
+	})
+	response.Text = string(rewritten)
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(rewritten)
+		return
+	}
+	json.NewEncoder(w).Encode(response)
+}
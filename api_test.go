@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postBatch(t *testing.T, contentType, body string) (*httptest.ResponseRecorder, BatchGenerateResponse) {
+	t.Helper()
+	r := httptest.NewRequest("POST", "/api/generate/batch", strings.NewReader(body))
+	r.Host = "proxy.local"
+	r.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	batchGenerateLinksAPI(w, r)
+	var resp BatchGenerateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON %q", w.Body.String())
+	}
+	return w, resp
+}
+
+func TestBatchGenerateKeepsOrder(t *testing.T) {
+	withConfig(t, func(cfg *Config) {
+		cfg.API.BatchConcurrency = 4
+		cfg.RefResolver.Enabled = true
+	})
+	old := repoRefs
+	repoRefs = newRefCache()
+	t.Cleanup(func() { repoRefs = old })
+	// 排在前面的仓库查询得更慢，完成顺序与输入顺序相反
+	stubUpstream(t, func(r *http.Request) (*http.Response, error) {
+		var n int
+		fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/o/r"), "%d", &n)
+		time.Sleep(time.Duration(8-n) * 5 * time.Millisecond)
+		body := pktLine("0123456789abcdef0123456789abcdef01234567 refs/heads/main\n") + "0000"
+		return stubResponse(r, http.StatusOK, nil, body), nil
+	})
+
+	var urls []string
+	for i := 0; i < 8; i++ {
+		urls = append(urls, fmt.Sprintf("https://github.com/o/r%d/blob/main/a.txt", i))
+	}
+	urls = append(urls, "https://example.com/a.txt", "not a url")
+	body, _ := json.Marshal(BatchGenerateRequest{URLs: urls})
+	w, resp := postBatch(t, "application/json", string(body))
+
+	if w.Code != http.StatusOK || !resp.Success {
+		t.Fatalf("status %d, response %+v", w.Code, resp)
+	}
+	if resp.Total != 10 || resp.Succeeded != 8 || resp.Failed != 2 {
+		t.Errorf("total %d, succeeded %d, failed %d; want 10, 8, 2", resp.Total, resp.Succeeded, resp.Failed)
+	}
+	for i, item := range resp.Results {
+		if item.OriginalURL != urls[i] {
+			t.Errorf("results[%d] = %s, want %s", i, item.OriginalURL, urls[i])
+		}
+		if i < 8 && (!item.Success || item.BrowserLink != "http://proxy.local/"+urls[i]) {
+			t.Errorf("results[%d] = %+v", i, item.GenerateLinksResponse)
+		}
+		if i >= 8 && (item.Success || item.Error == "") {
+			t.Errorf("results[%d] should fail: %+v", i, item.GenerateLinksResponse)
+		}
+	}
+}
+
+func TestBatchGenerateRequests(t *testing.T) {
+	withConfig(t, func(cfg *Config) { cfg.API.MaxBatchSize = 2 })
+
+	// 纯文本，忽略空行和注释
+	w, resp := postBatch(t, "text/plain", "# deps\nhttps://github.com/o/r/blob/main/a.txt\n\nhttps://github.com/o/r\n")
+	if w.Code != http.StatusOK || resp.Total != 2 || resp.Succeeded != 2 {
+		t.Errorf("text body: status %d, response %+v", w.Code, resp)
+	}
+
+	tests := []struct {
+		contentType string
+		body        string
+		status      int
+	}{
+		{"application/json", `{"urls":[]}`, http.StatusBadRequest},
+		{"text/plain", "\n# only comments\n", http.StatusBadRequest},
+		{"application/json", `{"urls":`, http.StatusBadRequest},
+		{"application/json", `{"urls":["https://github.com/a/b","https://github.com/c/d"],"text":"https://github.com/e/f"}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		w, resp := postBatch(t, tt.contentType, tt.body)
+		if w.Code != tt.status || resp.Success || resp.Error == "" {
+			t.Errorf("%q: status %d, response %+v; want %d", tt.body, w.Code, resp, tt.status)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	batchGenerateLinksAPI(w, httptest.NewRequest("GET", "/api/generate/batch", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status %d, want 405", w.Code)
+	}
+}
+
+func TestRewriteTextAPI(t *testing.T) {
+	text := "FROM alpine\nRUN wget https://github.com/o/r/releases/download/v1/tool.tar.gz && curl https://example.com/x\n"
+	want := "FROM alpine\nRUN wget http://proxy.local/https://github.com/o/r/releases/download/v1/tool.tar.gz && curl https://example.com/x\n"
+
+	body, _ := json.Marshal(RewriteTextRequest{Text: text})
+	r := httptest.NewRequest("POST", "/api/rewrite", strings.NewReader(string(body)))
+	r.Host = "proxy.local"
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	rewriteTextAPI(w, r)
+
+	var resp RewriteTextResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON %q", w.Body.String())
+	}
+	if !resp.Success || resp.Text != want {
+		t.Fatalf("response %+v", resp)
+	}
+	if len(resp.Changed) != 1 || resp.Changed[0].Original != "https://github.com/o/r/releases/download/v1/tool.tar.gz" ||
+		resp.Changed[0].Proxied != "http://proxy.local/https://github.com/o/r/releases/download/v1/tool.tar.gz" {
+		t.Errorf("changed = %+v", resp.Changed)
+	}
+	if len(resp.Skipped) != 1 || resp.Skipped[0].Original != "https://example.com/x" || resp.Skipped[0].Reason != "不支持的域名" {
+		t.Errorf("skipped = %+v", resp.Skipped)
+	}
+
+	// ?format=text 直接返回改写后的文本
+	r = httptest.NewRequest("POST", "/api/rewrite?format=text", strings.NewReader(text))
+	r.Host = "proxy.local"
+	w = httptest.NewRecorder()
+	rewriteTextAPI(w, r)
+	if w.Body.String() != want || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("format=text: %q (%s)", w.Body.String(), w.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+)
+
+// CommandTemplate 自定义下载命令模板，占位符写作 {{name}}，可用的占位符见 commandVarNames
+type CommandTemplate struct {
+	// 命令标识，作为API响应中 commands 的键
+	Name string `json:"name"`
+	// Web界面中显示的标签名
+	Label string `json:"label"`
+	// 命令模板，如 aria2c -o {{filename}} {{url}}
+	Template string `json:"template"`
+	// 命令所在的shell，决定占位符的值如何加引号：posix（默认）或 powershell
+	Shell string `json:"shell,omitempty"`
+}
+
+// 命令模板的shell类型
+const (
+	shellPOSIX      = "posix"
+	shellPowerShell = "powershell"
+)
+
+// 内置命令模板，配置文件中的 command_templates 会整体替换这里的内容
+var defaultCommandTemplates = []CommandTemplate{
+	{Name: "aria2c", Label: "aria2c", Template: `aria2c -x 8 -o {{filename}} {{url}}`},
+	{Name: "powershell", Label: "PowerShell", Template: `Invoke-WebRequest -Uri {{url}} -OutFile {{filename}}`, Shell: shellPowerShell},
+	{Name: "git_shallow", Label: "git clone --depth 1", Template: `git clone --depth 1 {{clone_url}}`},
+}
+
+// 模板占位符。占位符的值总是按shell加引号，模板中包在占位符外的引号一并替换
+var commandPlaceholder = regexp.MustCompile(`"\{\{\s*([a-z_]+)\s*\}\}"|'\{\{\s*([a-z_]+)\s*\}\}'|\{\{\s*([a-z_]+)\s*\}\}`)
+
+// 占位符的变量名
+func placeholderName(m []string) string {
+	for _, name := range m[1:] {
+		if name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// 按shell为命令参数加引号
+func quoteForShell(shell, s string) string {
+	if shell == shellPowerShell {
+		return powershellQuote(s)
+	}
+	return shellQuote(s)
+}
+
+// 模板中可用的占位符
+var commandVarNames = []string{
+	"url",          // 加速链接
+	"original_url", // 原始链接
+	"base_url",     // 代理服务地址
+	"filename",     // 文件名
+	"clone_url",    // 经过代理的git clone地址
+	"platform",     // 平台：github、gitlab、huggingface
+	"host",         // 原始链接的主机名
+	"namespace",    // 用户、组织或组
+	"repo",         // 仓库名
+	"repo_id",      // namespace/repo
+	"repo_type",    // Hugging Face仓库类型
+	"ref",          // 分支、标签或提交
+	"path",         // 仓库内的文件路径
+}
+
+// 检查命令模板：名称不能重复，只能使用已知的占位符
+func validateCommandTemplates(templates []CommandTemplate) error {
+	known := make(map[string]bool, len(commandVarNames))
+	for _, name := range commandVarNames {
+		known[name] = true
+	}
+	seen := make(map[string]bool, len(templates))
+	for _, t := range templates {
+		if t.Name == "" || t.Template == "" {
+			return fmt.Errorf("命令模板的 name 和 template 不能为空")
+		}
+		if seen[t.Name] {
+			return fmt.Errorf("命令模板名称重复: %s", t.Name)
+		}
+		seen[t.Name] = true
+		switch t.Shell {
+		case "", shellPOSIX, shellPowerShell:
+		default:
+			return fmt.Errorf("命令模板 %s 的 shell 无效: %q（可选 %s、%s）", t.Name, t.Shell, shellPOSIX, shellPowerShell)
+		}
+		for _, m := range commandPlaceholder.FindAllStringSubmatch(t.Template, -1) {
+			if name := placeholderName(m); !known[name] {
+				return fmt.Errorf("命令模板 %s 使用了未知的占位符 {{%s}}", t.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// 推断下载的文件名
+func downloadFileName(u *url.URL, src *SourceURL) string {
+	if src != nil && src.FilePath != "" {
+		if name := src.FileName(); name != "" {
+			return name
+		}
+	}
+	if name := path.Base(u.Path); name != "" && name != "/" && name != "." {
+		return name
+	}
+	return "downloaded_file"
+}
+
+// 模板变量
+func commandVars(baseURL, originalURL, acceleratedURL, fileName string, src *SourceURL) map[string]string {
+	vars := map[string]string{
+		"url":          acceleratedURL,
+		"original_url": originalURL,
+		"base_url":     baseURL,
+		"filename":     fileName,
+	}
+	if src != nil {
+		vars["platform"] = src.Platform
+		vars["host"] = src.Host
+		vars["namespace"] = src.Namespace
+		vars["repo"] = src.Repo
+		vars["repo_id"] = src.RepoID()
+		vars["repo_type"] = src.RepoType
+		vars["ref"] = src.Ref
+		vars["path"] = src.FilePath
+		if cloneURL := gitCloneURL(src); cloneURL != "" {
+			vars["clone_url"] = baseURL + "/" + cloneURL
+		}
+	}
+	return vars
+}
+
+// 渲染所有命令模板，占位符的值按模板的shell加引号；模板中用到的字段为空时（如文件链接没有clone_url）跳过该命令
+func renderCommands(templates []CommandTemplate, vars map[string]string) map[string]string {
+	commands := make(map[string]string, len(templates))
+	for _, t := range templates {
+		missing := false
+		rendered := commandPlaceholder.ReplaceAllStringFunc(t.Template, func(m string) string {
+			value := vars[placeholderName(commandPlaceholder.FindStringSubmatch(m))]
+			if value == "" {
+				missing = true
+			}
+			return quoteForShell(t.Shell, value)
+		})
+		if !missing {
+			commands[t.Name] = rendered
+		}
+	}
+	return commands
+}
+
+// 命令模板列表接口，供Web界面生成标签
+func commandsAPI(w http.ResponseWriter, r *http.Request) {
+	setAPIHeaders(w, "GET, OPTIONS")
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	type commandInfo struct {
+		Name  string `json:"name"`
+		Label string `json:"label"`
+	}
+	list := make([]commandInfo, 0, len(config.CommandTemplates))
+	for _, t := range config.CommandTemplates {
+		label := t.Label
+		if label == "" {
+			label = t.Name
+		}
+		list = append(list, commandInfo{Name: t.Name, Label: label})
+	}
+	json.NewEncoder(w).Encode(list)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderCommandsQuoting(t *testing.T) {
+	templates := []CommandTemplate{
+		{Name: "aria2c", Template: `aria2c -o {{filename}} {{url}}`},
+		{Name: "legacy", Template: `wget -O "{{filename}}" '{{url}}'`},
+		{Name: "prefixed", Template: `curl --output={{filename}} {{url}}`, Shell: shellPOSIX},
+		{Name: "powershell", Template: `Invoke-WebRequest -Uri "{{url}}" -OutFile {{filename}}`, Shell: shellPowerShell},
+		{Name: "clone", Template: `git clone {{clone_url}}`},
+	}
+	vars := map[string]string{
+		"url":      `http://proxy/https://github.com/o/r/raw/main/a"$(id)`,
+		"filename": `it's $(rm -rf ~)’.txt`,
+	}
+	got := renderCommands(templates, vars)
+	want := map[string]string{
+		"aria2c":     `aria2c -o 'it'\''s $(rm -rf ~)’.txt' 'http://proxy/https://github.com/o/r/raw/main/a"$(id)'`,
+		"legacy":     `wget -O 'it'\''s $(rm -rf ~)’.txt' 'http://proxy/https://github.com/o/r/raw/main/a"$(id)'`,
+		"prefixed":   `curl --output='it'\''s $(rm -rf ~)’.txt' 'http://proxy/https://github.com/o/r/raw/main/a"$(id)'`,
+		"powershell": `Invoke-WebRequest -Uri 'http://proxy/https://github.com/o/r/raw/main/a"$(id)' -OutFile 'it''s $(rm -rf ~)’’.txt'`,
+	}
+	if len(got) != len(want) {
+		t.Errorf("renderCommands returned %d commands, want %d (clone_url is empty): %v", len(got), len(want), got)
+	}
+	for name, cmd := range want {
+		if got[name] != cmd {
+			t.Errorf("%s:\n got  %s\n want %s", name, got[name], cmd)
+		}
+	}
+}
+
+func TestValidateCommandTemplates(t *testing.T) {
+	tests := []struct {
+		templates []CommandTemplate
+		want      string
+	}{
+		{defaultCommandTemplates, ""},
+		{[]CommandTemplate{{Name: "a", Template: `echo "{{ url }}"`}}, ""},
+		{[]CommandTemplate{{Name: "a", Template: "echo {{token}}"}}, "未知的占位符 {{token}}"},
+		{[]CommandTemplate{{Name: "a", Template: "echo {{url}}", Shell: "cmd"}}, "shell 无效"},
+		{[]CommandTemplate{{Name: "a", Template: "x"}, {Name: "a", Template: "y"}}, "名称重复"},
+		{[]CommandTemplate{{Name: "a"}}, "不能为空"},
+	}
+	for _, tt := range tests {
+		err := validateCommandTemplates(tt.templates)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("validateCommandTemplates(%v) = %v", tt.templates, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("validateCommandTemplates(%v) = %v, want error containing %q", tt.templates, err, tt.want)
+		}
+	}
+}
+
+func TestGitCloneCommandQuotesRef(t *testing.T) {
+	src := &SourceURL{
+		Platform: platformGitHub, Host: "github.com", Namespace: "o", Repo: "r",
+		Kind: SourceTree, Ref: "feat/$(id)", RefResolved: true,
+	}
+	want := `git clone -b 'feat/$(id)' 'http://proxy/https://github.com/o/r.git'`
+	if got := gitCloneCommand("http://proxy", src); got != want {
+		t.Errorf("gitCloneCommand = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Config 服务运行配置，默认值见 defaultConfig，可通过JSON配置文件和环境变量覆盖
+type Config struct {
+	// 监听地址
+	Listen string `json:"listen"`
+
+	// 启用的平台：github、gitlab、huggingface，未启用平台的域名不会被代理
+	Platforms []string `json:"platforms"`
+
+	// 虚拟主机模式：入站主机名 -> 上游主机名，如 "raw.gh.example.com": "raw.githubusercontent.com"
+	VHosts map[string]string `json:"vhosts"`
+
+	// 出站访问策略
+	Egress EgressConfig `json:"egress"`
+
+	// 代理请求策略
+	Request RequestConfig `json:"request"`
+
+	// 上游重定向处理
+	Redirect RedirectConfig `json:"redirect"`
+
+	// 分支名解析
+	RefResolver RefResolverConfig `json:"ref_resolver"`
+
+	// API接口配置
+	API APIConfig `json:"api"`
+
+	// ghcr.io 镜像仓库
+	Registry RegistryConfig `json:"registry"`
+
+	// Go模块代理
+	GoProxy GoProxyConfig `json:"goproxy"`
+
+	// GitHub REST API 代理
+	GitHubAPI GitHubAPIConfig `json:"github_api"`
+
+	// 目录打包下载
+	TreeArchive TreeArchiveConfig `json:"tree_archive"`
+
+	// 上游API令牌池
+	Tokens TokensConfig `json:"tokens"`
+
+	// 管理接口
+	Admin AdminConfig `json:"admin"`
+
+	// 链接生成时的自定义命令模板
+	CommandTemplates []CommandTemplate `json:"command_templates"`
+
+	// 上游请求头配置，键为 "平台/请求类型"（如 "github/git"、"*/file"），覆盖同名的内置配置
+	HeaderProfiles map[string]HeaderProfile `json:"header_profiles"`
+}
+
+// EgressConfig 出站请求安全策略
+type EgressConfig struct {
+	// 是否允许以http协议访问上游（默认只允许https）
+	AllowHTTP bool `json:"allow_http"`
+	// 额外禁止访问的网段（CIDR格式），在内置的私有/回环/元数据网段之外生效
+	DeniedCIDRs []string `json:"denied_cidrs"`
+}
+
+// RequestConfig 代理请求的方法和请求体策略
+type RequestConfig struct {
+	// 请求体大小上限（字节），只对允许携带请求体的请求生效
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+}
+
+// RedirectConfig 上游重定向处理方式
+type RedirectConfig struct {
+	// follow：服务端跟随重定向；passthrough：返回3xx并把Location改写为经过代理
+	Mode string `json:"mode"`
+	// 服务端跟随的最大跳数，超过后返回3xx给客户端
+	MaxHops int `json:"max_hops"`
+}
+
+// RefResolverConfig 通过上游ref列表拆分带斜杠的分支名
+type RefResolverConfig struct {
+	Enabled bool `json:"enabled"`
+	// ref列表缓存时间（秒）
+	CacheTTL int `json:"cache_ttl"`
+}
+
+// APIConfig 链接生成等API接口配置
+type APIConfig struct {
+	// 批量生成接口单次最多处理的链接数
+	MaxBatchSize int `json:"max_batch_size"`
+	// 批量生成的并发数
+	BatchConcurrency int `json:"batch_concurrency"`
+}
+
+// RegistryConfig ghcr.io 镜像仓库配置
+type RegistryConfig struct {
+	// 按digest缓存manifest和镜像层的目录，为空时不缓存
+	CacheDir string `json:"cache_dir"`
+	// 缓存大小上限（字节），超过后删除最久未使用的文件；0表示不限制
+	CacheMaxBytes int64 `json:"cache_max_bytes"`
+}
+
+// GoProxyConfig Go模块代理配置
+type GoProxyConfig struct {
+	// 模块版本的缓存目录，为空时使用系统临时目录下的 ghproxy-goproxy。已发布版本的内容不会变化，缓存不过期
+	CacheDir string `json:"cache_dir"`
+}
+
+// GitHubAPIConfig GitHub REST API 代理配置
+type GitHubAPIConfig struct {
+	// 访问 api.github.com 使用的服务端令牌，为空时匿名访问。缓存的响应对所有用户可见，应使用只能读取公开仓库的令牌
+	Token string `json:"token"`
+	// 缓存的新鲜时间（秒），过期后向上游发送条件请求重新验证
+	CacheTTL int `json:"cache_ttl"`
+	// 缓存大小上限（字节）
+	CacheMaxBytes int64 `json:"cache_max_bytes"`
+}
+
+// TreeArchiveConfig 目录打包下载的限制
+type TreeArchiveConfig struct {
+	// 单次打包的最大文件数
+	MaxFiles int `json:"max_files"`
+	// 单次打包的文件总大小上限（字节）
+	MaxBytes int64 `json:"max_bytes"`
+}
+
+// TokensConfig 上游API令牌池，同一平台的多个令牌按剩余额度轮换使用。
+// 令牌用于服务端发起的API请求（GitHub API 缓存、固定版本、Go模块代理、HF快照清单），应只授予公开仓库的读取权限
+type TokensConfig struct {
+	// api.github.com 令牌，与 github_api.token 合并使用
+	GitHub []string `json:"github"`
+	// gitlab.com API 令牌
+	GitLab []string `json:"gitlab"`
+	// Hugging Face API 令牌
+	HuggingFace []string `json:"huggingface"`
+}
+
+// AdminConfig 管理接口配置
+type AdminConfig struct {
+	// 管理接口的访问令牌（Authorization: Bearer），为空时不开放管理接口
+	Token string `json:"token"`
+}
+
+// 是否启用了指定平台
+func (c *Config) platformEnabled(platform string) bool {
+	for _, p := range c.Platforms {
+		if p == platform {
+			return true
+		}
+	}
+	return false
+}
+
+// 全局配置
+var config = defaultConfig()
+
+func defaultConfig() *Config {
+	return &Config{
+		Listen:    ":8080",
+		Platforms: []string{platformGitHub, platformGitLab, platformHuggingFace},
+		Request: RequestConfig{
+			MaxBodyBytes: defaultMaxBodyBytes,
+		},
+		Redirect: RedirectConfig{
+			Mode:    redirectFollow,
+			MaxHops: 10,
+		},
+		RefResolver: RefResolverConfig{
+			CacheTTL: 300,
+		},
+		API: APIConfig{
+			MaxBatchSize:     500,
+			BatchConcurrency: 8,
+		},
+		Registry: RegistryConfig{
+			CacheMaxBytes: 10 << 30,
+		},
+		GitHubAPI: GitHubAPIConfig{
+			CacheTTL:      60,
+			CacheMaxBytes: 64 << 20,
+		},
+		TreeArchive: TreeArchiveConfig{
+			MaxFiles: 1000,
+			MaxBytes: 100 << 20,
+		},
+		CommandTemplates: defaultCommandTemplates,
+	}
+}
+
+// 加载配置：先读取配置文件（GHPROXY_CONFIG 指定，默认 config.json，不存在则跳过），再应用环境变量
+func loadConfig() (*Config, error) {
+	cfg := defaultConfig()
+
+	path := os.Getenv("GHPROXY_CONFIG")
+	explicit := path != ""
+	if !explicit {
+		path = "config.json"
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+		}
+		log.Printf("已加载配置文件: %s", path)
+	case os.IsNotExist(err) && !explicit:
+		// 未指定配置文件且默认文件不存在，使用默认配置
+	default:
+		return nil, fmt.Errorf("读取配置文件 %s 失败: %w", path, err)
+	}
+
+	applyEnv(cfg)
+
+	for _, p := range cfg.Platforms {
+		if _, ok := platformDomains[p]; !ok {
+			return nil, fmt.Errorf("未知的平台 %q（可选 %s、%s、%s）", p, platformGitHub, platformGitLab, platformHuggingFace)
+		}
+	}
+
+	if err := validateVHosts(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := validateCommandTemplates(cfg.CommandTemplates); err != nil {
+		return nil, err
+	}
+
+	switch cfg.Redirect.Mode {
+	case redirectFollow, redirectPassthrough:
+	default:
+		return nil, fmt.Errorf("无效的重定向模式 %q（可选 %s、%s）", cfg.Redirect.Mode, redirectFollow, redirectPassthrough)
+	}
+	if cfg.Redirect.MaxHops <= 0 {
+		return nil, fmt.Errorf("redirect.max_hops 必须大于0，当前为 %d", cfg.Redirect.MaxHops)
+	}
+	if cfg.API.MaxBatchSize <= 0 {
+		return nil, fmt.Errorf("api.max_batch_size 必须大于0，当前为 %d", cfg.API.MaxBatchSize)
+	}
+	if cfg.API.BatchConcurrency <= 0 {
+		return nil, fmt.Errorf("api.batch_concurrency 必须大于0，当前为 %d", cfg.API.BatchConcurrency)
+	}
+	if cfg.TreeArchive.MaxFiles <= 0 {
+		return nil, fmt.Errorf("tree_archive.max_files 必须大于0，当前为 %d", cfg.TreeArchive.MaxFiles)
+	}
+	if cfg.TreeArchive.MaxBytes <= 0 {
+		return nil, fmt.Errorf("tree_archive.max_bytes 必须大于0，当前为 %d", cfg.TreeArchive.MaxBytes)
+	}
+	return cfg, nil
+}
+
+// 环境变量覆盖配置文件中的常用项，便于Docker部署
+func applyEnv(cfg *Config) {
+	envString("GHPROXY_LISTEN", &cfg.Listen)
+	envList("GHPROXY_PLATFORMS", &cfg.Platforms)
+	envMap("GHPROXY_VHOSTS", &cfg.VHosts)
+	envBool("GHPROXY_ALLOW_HTTP", &cfg.Egress.AllowHTTP)
+	envList("GHPROXY_DENIED_CIDRS", &cfg.Egress.DeniedCIDRs)
+	envInt64("GHPROXY_MAX_BODY_BYTES", &cfg.Request.MaxBodyBytes)
+	envString("GHPROXY_REDIRECT_MODE", &cfg.Redirect.Mode)
+	envInt("GHPROXY_REDIRECT_MAX_HOPS", &cfg.Redirect.MaxHops)
+	envBool("GHPROXY_RESOLVE_REFS", &cfg.RefResolver.Enabled)
+	envInt("GHPROXY_REF_CACHE_TTL", &cfg.RefResolver.CacheTTL)
+	envInt("GHPROXY_MAX_BATCH_SIZE", &cfg.API.MaxBatchSize)
+	envInt("GHPROXY_BATCH_CONCURRENCY", &cfg.API.BatchConcurrency)
+	envString("GHPROXY_REGISTRY_CACHE_DIR", &cfg.Registry.CacheDir)
+	envInt64("GHPROXY_REGISTRY_CACHE_MAX_BYTES", &cfg.Registry.CacheMaxBytes)
+	envString("GHPROXY_GOPROXY_CACHE_DIR", &cfg.GoProxy.CacheDir)
+	envString("GHPROXY_GITHUB_TOKEN", &cfg.GitHubAPI.Token)
+	envInt("GHPROXY_GITHUB_API_CACHE_TTL", &cfg.GitHubAPI.CacheTTL)
+	envInt64("GHPROXY_GITHUB_API_CACHE_MAX_BYTES", &cfg.GitHubAPI.CacheMaxBytes)
+	envInt("GHPROXY_TREE_ARCHIVE_MAX_FILES", &cfg.TreeArchive.MaxFiles)
+	envInt64("GHPROXY_TREE_ARCHIVE_MAX_BYTES", &cfg.TreeArchive.MaxBytes)
+	envList("GHPROXY_GITHUB_TOKENS", &cfg.Tokens.GitHub)
+	envList("GHPROXY_GITLAB_TOKENS", &cfg.Tokens.GitLab)
+	envList("GHPROXY_HF_TOKENS", &cfg.Tokens.HuggingFace)
+	envString("GHPROXY_ADMIN_TOKEN", &cfg.Admin.Token)
+}
+
+func envString(key string, dst *string) {
+	if v, ok := os.LookupEnv(key); ok {
+		*dst = strings.TrimSpace(v)
+	}
+}
+
+func envBool(key string, dst *bool) {
+	if v, ok := os.LookupEnv(key); ok {
+		if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+			*dst = b
+		} else {
+			log.Printf("环境变量 %s 不是有效的布尔值: %q", key, v)
+		}
+	}
+}
+
+func envInt(key string, dst *int) {
+	if v, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			*dst = n
+		} else {
+			log.Printf("环境变量 %s 不是有效的整数: %q", key, v)
+		}
+	}
+}
+
+func envInt64(key string, dst *int64) {
+	if v, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+			*dst = n
+		} else {
+			log.Printf("环境变量 %s 不是有效的整数: %q", key, v)
+		}
+	}
+}
+
+// 逗号分隔的列表
+func envList(key string, dst *[]string) {
+	if v, ok := os.LookupEnv(key); ok {
+		var items []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*dst = items
+	}
+}
+
+// 逗号分隔的 key=value 列表
+func envMap(key string, dst *map[string]string) {
+	if v, ok := os.LookupEnv(key); ok {
+		items := make(map[string]string)
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			k, value, ok := strings.Cut(item, "=")
+			if !ok {
+				log.Printf("环境变量 %s 的项不是 key=value 格式: %q", key, item)
+				continue
+			}
+			items[strings.TrimSpace(k)] = strings.TrimSpace(value)
+		}
+		*dst = items
+	}
+}
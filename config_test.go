@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 使用临时配置文件加载配置
+func loadTestConfig(t *testing.T, content string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GHPROXY_CONFIG", path)
+	return loadConfig()
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cfg, err := loadTestConfig(t, `{}`)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if cfg.Redirect.MaxHops != 10 || cfg.Redirect.Mode != redirectFollow {
+		t.Errorf("redirect = %+v", cfg.Redirect)
+	}
+}
+
+func TestLoadConfigRejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{`{"redirect": {"mode": "bounce"}}`, "重定向模式"},
+		{`{"redirect": {"max_hops": 0}}`, "redirect.max_hops"},
+		{`{"redirect": {"max_hops": -1}}`, "redirect.max_hops"},
+		{`{"api": {"max_batch_size": 0}}`, "api.max_batch_size"},
+		{`{"api": {"batch_concurrency": 0}}`, "api.batch_concurrency"},
+		{`{"tree_archive": {"max_files": 0}}`, "tree_archive.max_files"},
+		{`{"tree_archive": {"max_bytes": -1}}`, "tree_archive.max_bytes"},
+	}
+	for _, tt := range tests {
+		_, err := loadTestConfig(t, tt.content)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("loadConfig(%s) = %v, want error containing %q", tt.content, err, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigEnvOverride(t *testing.T) {
+	t.Setenv("GHPROXY_REDIRECT_MAX_HOPS", "0")
+	if _, err := loadTestConfig(t, `{}`); err == nil {
+		t.Error("GHPROXY_REDIRECT_MAX_HOPS=0 should be rejected")
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 内置禁止访问的网段：私有地址、回环、链路本地（含云厂商元数据地址）、运营商NAT、保留地址等
+var builtinDeniedPrefixes = mustParsePrefixes(
+	"0.0.0.0/8",         // 本网络
+	"10.0.0.0/8",        // 私有网络
+	"100.64.0.0/10",     // 运营商级NAT
+	"127.0.0.0/8",       // 回环
+	"169.254.0.0/16",    // 链路本地，包含 169.254.169.254 元数据服务
+	"172.16.0.0/12",     // 私有网络
+	"192.0.0.0/24",      // IETF协议分配
+	"192.0.2.0/24",      // 文档示例
+	"192.168.0.0/16",    // 私有网络
+	"198.18.0.0/15",     // 基准测试
+	"198.51.100.0/24",   // 文档示例
+	"203.0.113.0/24",    // 文档示例
+	"224.0.0.0/4",       // 组播
+	"240.0.0.0/4",       // 保留及广播
+	"::/128",            // 未指定地址
+	"::1/128",           // 回环
+	"64:ff9b::/96",      // NAT64，映射到IPv4地址
+	"64:ff9b:1::/48",    // 本地NAT64
+	"100::/64",          // 丢弃前缀
+	"2001:db8::/32",     // 文档示例
+	"2002::/16",         // 6to4，内嵌IPv4地址
+	"fc00::/7",          // 唯一本地地址
+	"fe80::/10",         // 链路本地
+	"ff00::/8",          // 组播
+	"fd00:ec2::254/128", // AWS IPv6元数据服务
+)
+
+func mustParsePrefixes(cidrs ...string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefixes = append(prefixes, netip.MustParsePrefix(cidr))
+	}
+	return prefixes
+}
+
+// 出站策略错误，代理处理器据此返回403
+var errEgressDenied = errors.New("egress denied")
+
+// 运行时生效的禁止网段（内置网段 + 配置中的额外网段）
+var deniedPrefixes = builtinDeniedPrefixes
+
+// 根据配置初始化出站策略
+func setupEgressPolicy(cfg EgressConfig) error {
+	prefixes := append([]netip.Prefix(nil), builtinDeniedPrefixes...)
+	for _, cidr := range cfg.DeniedCIDRs {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+		if err != nil {
+			return fmt.Errorf("无效的禁止网段 %q: %w", cidr, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	deniedPrefixes = prefixes
+	return nil
+}
+
+// 检查解析后的IP是否允许访问
+func isAllowedIP(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range deniedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// 规范化主机名：去掉端口、转小写、去掉末尾的点
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+// 允许的协议及其标准端口
+func allowedSchemePort(scheme string) (string, bool) {
+	switch strings.ToLower(scheme) {
+	case "https":
+		return "443", true
+	case "http":
+		return "80", config.Egress.AllowHTTP
+	}
+	return "", false
+}
+
+// 检查出站URL是否符合策略：协议、端口和域名白名单。通过时会规范化URL中的主机名
+func checkEgressURL(u *url.URL) error {
+	port, ok := allowedSchemePort(u.Scheme)
+	if !ok {
+		return fmt.Errorf("%w: 不允许的协议 %q", errEgressDenied, u.Scheme)
+	}
+	if p := u.Port(); p != "" && p != port {
+		return fmt.Errorf("%w: %s 只允许使用标准端口 %s", errEgressDenied, u.Scheme, port)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: URL中不允许包含用户信息", errEgressDenied)
+	}
+
+	host := normalizeHost(u.Hostname())
+	// 白名单中都是ASCII域名，国际化域名直接拒绝
+	if !isASCII(host) {
+		return fmt.Errorf("%w: 不支持国际化域名 %s", errEgressDenied, host)
+	}
+	if !isSupportedDomain(host) {
+		return fmt.Errorf("%w: 不支持的域名 %s", errEgressDenied, host)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = host
+	return nil
+}
+
+// 拨号时校验实际连接的地址，防止DNS解析到内网地址（包括DNS重绑定）
+func egressDialControl(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: 无法解析连接地址 %s", errEgressDenied, address)
+	}
+	if !isAllowedIP(addrPort.Addr()) {
+		log.Printf("拒绝连接内网或保留地址: %s", address)
+		return fmt.Errorf("%w: 禁止连接地址 %s", errEgressDenied, addrPort.Addr())
+	}
+	switch addrPort.Port() {
+	case 443:
+	case 80:
+		if !config.Egress.AllowHTTP {
+			return fmt.Errorf("%w: 禁止连接端口 %d", errEgressDenied, addrPort.Port())
+		}
+	default:
+		return fmt.Errorf("%w: 禁止连接端口 %d", errEgressDenied, addrPort.Port())
+	}
+	return nil
+}
+
+// 上游请求使用的Transport，所有出站连接都经过 egressDialControl 校验
+func newUpstreamTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   egressDialControl,
+	}
+	return &http.Transport{
+		// 不使用环境变量中的代理，否则拨号校验的对象会变成代理服务器
+		Proxy: nil,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   16,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// 上游HTTP客户端，不设置整体超时以支持大文件传输
+var upstreamClient = &http.Client{
+	Transport:     newUpstreamTransport(),
+	CheckRedirect: checkUpstreamRedirect,
+}
+
+// 跟随重定向时对目标URL应用同样的出站策略。不跟随的重定向会原样返回给代理处理器，由其改写Location
+func checkUpstreamRedirect(req *http.Request, via []*http.Request) error {
+	if config.Redirect.Mode == redirectPassthrough {
+		return http.ErrUseLastResponse
+	}
+	// 跳数过多或非GET/HEAD请求（如git-upload-pack）不在服务端跟随
+	if len(via) >= config.Redirect.MaxHops {
+		log.Printf("重定向次数超过 %d，返回给客户端", config.Redirect.MaxHops)
+		return http.ErrUseLastResponse
+	}
+	if method := via[0].Method; method != http.MethodGet && method != http.MethodHead {
+		return http.ErrUseLastResponse
+	}
+	// Hugging Face Hub的 HEAD /resolve/ 只在同一主机内跟随
+	if isHubResolveHEAD(via[0]) && req.URL.Host != via[0].URL.Host {
+		return http.ErrUseLastResponse
+	}
+
+	if err := checkEgressURL(req.URL); err != nil {
+		// LFS对象可能跳转到平台的对象存储（如GitLab的云存储），交给客户端直接下载
+		if isLFSObjectURL(via[0].URL) {
+			log.Printf("LFS对象重定向到不支持的域名，返回给客户端: %s", req.URL.String())
+			return http.ErrUseLastResponse
+		}
+		log.Printf("拒绝重定向: %s (%v)", req.URL.String(), err)
+		return err
+	}
+
+	// 跨主机时不携带客户端的访问令牌（如跳转到签名的CDN地址）
+	if req.URL.Host != via[0].URL.Host {
+		req.Header.Del("Authorization")
+	}
+
+	log.Printf("跟随重定向: %s -> %s", via[len(via)-1].URL.String(), req.URL.String())
+	return nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+func TestIsAllowedIP(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"140.82.112.3", true},
+		{"2606:50c0:8000::154", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"fd00:ec2::254", false},
+		{"64:ff9b::7f00:1", false},    // NAT64 -> 127.0.0.1
+		{"64:ff9b::a9fe:a9fe", false}, // NAT64 -> 169.254.169.254
+		{"2002:7f00:1::", false},      // 6to4 内嵌 127.0.0.1
+		{"2002:a00:1::1", false},      // 6to4 内嵌 10.0.0.1
+	}
+	for _, tt := range tests {
+		if got := isAllowedIP(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("isAllowedIP(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestCheckEgressURL(t *testing.T) {
+	tests := []struct {
+		raw      string
+		wantHost string
+		denied   bool
+	}{
+		{"https://github.com/owner/repo", "github.com", false},
+		{"https://GitHub.COM./owner/repo", "github.com", false},
+		{"https://github.com:443/owner/repo", "github.com", false},
+		{"https://github.com:8443/owner/repo", "", true},
+		{"http://github.com/owner/repo", "", true},
+		{"ftp://github.com/owner/repo", "", true},
+		{"https://user@github.com/owner/repo", "", true},
+		{"https://release-assets.githubusercontent.com/github-production-release-asset/1/2", "release-assets.githubusercontent.com", false},
+		{"https://objects.githubusercontent.com/github-production-release-asset-2e65be/1/2", "objects.githubusercontent.com", false},
+		{"https://example.com/file", "", true},
+		{"https://gíthub.com/owner/repo", "", true},
+		{"https://xn--gthub-2ta.com/owner/repo", "", true},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.raw)
+		if err != nil {
+			t.Fatalf("url.Parse(%q): %v", tt.raw, err)
+		}
+		err = checkEgressURL(u)
+		if tt.denied {
+			if !errors.Is(err, errEgressDenied) {
+				t.Errorf("checkEgressURL(%q) = %v, want errEgressDenied", tt.raw, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("checkEgressURL(%q) = %v", tt.raw, err)
+		} else if u.Host != tt.wantHost {
+			t.Errorf("checkEgressURL(%q) host = %q, want %q", tt.raw, u.Host, tt.wantHost)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// 各平台git仓库地址的前缀，git会把以这些前缀开头的地址改写为经过代理的地址
+var gitInsteadOfPrefixes = map[string]string{
+	platformGitHub:      "https://github.com/",
+	platformGitLab:      "https://gitlab.com/",
+	platformHuggingFace: "https://huggingface.co/",
+}
+
+// GitInsteadOfRule 一条 url.<base>.insteadOf 规则
+type GitInsteadOfRule struct {
+	Platform string `json:"platform"`
+	// 经过代理的地址前缀
+	ProxyPrefix string `json:"proxy_prefix"`
+	// 原始地址前缀
+	InsteadOf string `json:"instead_of"`
+}
+
+// GitConfigResponse git配置接口的JSON响应
+type GitConfigResponse struct {
+	Success   bool               `json:"success"`
+	BaseURL   string             `json:"base_url,omitempty"`
+	Rules     []GitInsteadOfRule `json:"rules,omitempty"`
+	Commands  string             `json:"commands,omitempty"`
+	GitConfig string             `json:"gitconfig,omitempty"`
+	Error     string             `json:"error,omitempty"`
+}
+
+// 生成insteadOf规则；platforms为空时使用所有已启用的平台
+func gitInsteadOfRules(baseURL string, platforms []string) ([]GitInsteadOfRule, error) {
+	if len(platforms) == 0 {
+		platforms = config.Platforms
+	}
+	if strings.IndexFunc(baseURL, unicode.IsControl) >= 0 {
+		return nil, fmt.Errorf("代理地址包含控制字符: %q", baseURL)
+	}
+	rules := make([]GitInsteadOfRule, 0, len(platforms))
+	for _, p := range platforms {
+		p = strings.TrimSpace(p)
+		prefix, ok := gitInsteadOfPrefixes[p]
+		if !ok {
+			return nil, fmt.Errorf("未知的平台 %q", p)
+		}
+		if !config.platformEnabled(p) {
+			return nil, fmt.Errorf("平台 %s 未启用", p)
+		}
+		rules = append(rules, GitInsteadOfRule{Platform: p, ProxyPrefix: baseURL + "/" + prefix, InsteadOf: prefix})
+	}
+	return rules, nil
+}
+
+// git config --global 命令，可以直接在shell中执行
+// 同时把原始地址的 pushInsteadOf 指向自身，git push 仍直接访问上游（代理不支持写入操作）
+func gitConfigCommands(rules []GitInsteadOfRule) string {
+	var b strings.Builder
+	for _, rule := range rules {
+		fmt.Fprintf(&b, "git config --global %s %s\n", shellQuote("url."+rule.ProxyPrefix+".insteadOf"), shellQuote(rule.InsteadOf))
+		fmt.Fprintf(&b, "git config --global %s %s\n", shellQuote("url."+rule.InsteadOf+".pushInsteadOf"), shellQuote(rule.InsteadOf))
+	}
+	return b.String()
+}
+
+// .gitconfig 配置片段
+func gitConfigSnippet(rules []GitInsteadOfRule) string {
+	var b strings.Builder
+	for _, rule := range rules {
+		fmt.Fprintf(&b, "[url %s]\n\tinsteadOf = %s\n", gitConfigSubsection(rule.ProxyPrefix), gitConfigValue(rule.InsteadOf))
+		fmt.Fprintf(&b, "[url %s]\n\tpushInsteadOf = %s\n", gitConfigSubsection(rule.InsteadOf), gitConfigValue(rule.InsteadOf))
+	}
+	return b.String()
+}
+
+// .gitconfig 小节名引用：小节名中只能转义 \ 和 "，换行无法表示（规则生成时已拒绝控制字符）
+func gitConfigSubsection(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// .gitconfig 值引用：放在双引号内，; 和 # 不再被当作注释，\ " 换行和制表符按git的规则转义
+func gitConfigValue(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// 按格式输出git配置：commands（默认）、gitconfig
+func renderGitConfig(rules []GitInsteadOfRule, format string) (string, error) {
+	switch format {
+	case "", "commands":
+		return gitConfigCommands(rules), nil
+	case "gitconfig":
+		return gitConfigSnippet(rules), nil
+	}
+	return "", fmt.Errorf("不支持的格式 %q（可选 commands、gitconfig、json）", format)
+}
+
+// git加速配置接口
+// GET /api/gitconfig?format=commands|gitconfig|json&platforms=github,gitlab
+func gitConfigAPI(w http.ResponseWriter, r *http.Request) {
+	setAPIHeaders(w, "GET, OPTIONS")
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != "GET" && r.Method != "HEAD" {
+		json.NewEncoder(w).Encode(GitConfigResponse{Success: false, Error: "只支持GET请求"})
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	var platforms []string
+	if v := query.Get("platforms"); v != "" {
+		platforms = strings.Split(v, ",")
+	}
+
+	baseURL := requestBaseURL(r)
+	rules, err := gitInsteadOfRules(baseURL, platforms)
+	if format == "json" {
+		if err != nil {
+			json.NewEncoder(w).Encode(GitConfigResponse{Success: false, Error: err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(GitConfigResponse{
+			Success:   true,
+			BaseURL:   baseURL,
+			Rules:     rules,
+			Commands:  gitConfigCommands(rules),
+			GitConfig: gitConfigSnippet(rules),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	out, err := renderGitConfig(rules, format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fmt.Fprint(w, out)
+}
+
+// 根据监听地址推断本机访问地址，如 :8080 -> http://127.0.0.1:8080
+func listenBaseURL(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "http://" + listen
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// ghproxy gitconfig 子命令：在命令行输出git加速配置
+func runGitConfigCommand(args []string) int {
+	flags := flag.NewFlagSet("gitconfig", flag.ContinueOnError)
+	base := flags.String("base", "", "代理服务地址，如 https://gh.example.com（默认根据监听地址推断）")
+	format := flags.String("format", "commands", "输出格式：commands、gitconfig")
+	platformList := flags.String("platforms", "", "逗号分隔的平台列表（默认所有已启用的平台）")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		return 1
+	}
+	config = cfg
+
+	baseURL := strings.TrimSuffix(*base, "/")
+	if baseURL == "" {
+		baseURL = listenBaseURL(config.Listen)
+	}
+	var platforms []string
+	if *platformList != "" {
+		platforms = strings.Split(*platformList, ",")
+	}
+
+	rules, err := gitInsteadOfRules(baseURL, platforms)
+	if err == nil {
+		var out string
+		if out, err = renderGitConfig(rules, *format); err == nil {
+			fmt.Print(out)
+			return 0
+		}
+	}
+	fmt.Fprintln(os.Stderr, err)
+	return 1
+}
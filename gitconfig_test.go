@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 包含shell和git配置特殊字符的Host
+const hostileHost = `x$(id)'y;#"z\w`
+
+func gitConfigRequest(t *testing.T, host, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest("GET", "/api/gitconfig?"+query, nil)
+	r.Host = host
+	w := httptest.NewRecorder()
+	gitConfigAPI(w, r)
+	return w
+}
+
+func TestGitConfigCommandsQuoted(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("未找到sh")
+	}
+	w := gitConfigRequest(t, hostileHost, "platforms=github")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	// 用shell函数代替git，逐行打印参数，检查引用后shell看到的参数与原文一致
+	script := "git() { shift 2; printf '%s\\n' \"$@\"; }\n" + w.Body.String()
+	out, err := exec.Command("sh", "-c", script).Output()
+	if err != nil {
+		t.Fatalf("sh: %v\n%s", err, script)
+	}
+	want := strings.Join([]string{
+		"url.http://" + hostileHost + "/https://github.com/.insteadOf",
+		"https://github.com/",
+		"url.https://github.com/.pushInsteadOf",
+		"https://github.com/",
+	}, "\n") + "\n"
+	if string(out) != want {
+		t.Errorf("shell参数 = %q, want %q", out, want)
+	}
+}
+
+func TestGitConfigSnippetQuoted(t *testing.T) {
+	w := gitConfigRequest(t, hostileHost, "format=gitconfig&platforms=github")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	want := `[url "http://x$(id)'y;#\"z\\w/https://github.com/"]` + "\n\tinsteadOf = \"https://github.com/\"\n" +
+		`[url "https://github.com/"]` + "\n\tpushInsteadOf = \"https://github.com/\"\n"
+	if got := w.Body.String(); got != want {
+		t.Fatalf("gitconfig =\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		return
+	}
+	// 让git解析生成的片段，确认小节名和值都按原文读出
+	path := filepath.Join(t.TempDir(), "gitconfig")
+	if err := os.WriteFile(path, w.Body.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("git", "config", "--file", path, "--get", "url.http://"+hostileHost+"/https://github.com/.insteadof").Output()
+	if err != nil {
+		t.Fatalf("git config: %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "https://github.com/" {
+		t.Errorf("git读取的insteadOf = %q", got)
+	}
+}
+
+func TestGitConfigValue(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://github.com/", `"https://github.com/"`},
+		{"a;b#c", `"a;b#c"`},
+		{`a"b\c`, `"a\"b\\c"`},
+		{"a\nb\tc", `"a\nb\tc"`},
+	}
+	for _, tt := range tests {
+		if got := gitConfigValue(tt.in); got != tt.want {
+			t.Errorf("gitConfigValue(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestGitConfigJSON(t *testing.T) {
+	w := gitConfigRequest(t, "gh.example.com", "format=json&platforms=github,gitlab")
+	var resp GitConfigResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Success || resp.BaseURL != "http://gh.example.com" {
+		t.Fatalf("resp = %+v", resp)
+	}
+	if len(resp.Rules) != 2 || resp.Rules[1].ProxyPrefix != "http://gh.example.com/https://gitlab.com/" || resp.Rules[1].InsteadOf != "https://gitlab.com/" {
+		t.Errorf("rules = %+v", resp.Rules)
+	}
+	if resp.Commands != gitConfigCommands(resp.Rules) || resp.GitConfig != gitConfigSnippet(resp.Rules) {
+		t.Errorf("commands/gitconfig 与规则不一致: %+v", resp)
+	}
+
+	w = gitConfigRequest(t, "gh.example.com", "format=json&platforms=svn")
+	resp = GitConfigResponse{}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Success || resp.Error == "" {
+		t.Errorf("未知平台应返回错误: %+v", resp)
+	}
+}
+
+func TestGitConfigRejectsBadInput(t *testing.T) {
+	if w := gitConfigRequest(t, "gh.example.com", "format=yaml"); w.Code != http.StatusBadRequest {
+		t.Errorf("未知格式 status = %d", w.Code)
+	}
+	if _, err := gitInsteadOfRules("http://a\nb", []string{platformGitHub}); err == nil {
+		t.Error("包含换行的代理地址应被拒绝")
+	}
+}
+
+// 执行 ghproxy gitconfig 子命令并返回标准输出
+func runGitConfigCapture(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"listen": ":9090"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GHPROXY_CONFIG", path)
+	saved := config
+	t.Cleanup(func() { config = saved })
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	code := runGitConfigCommand(args)
+	os.Stdout = stdout
+	w.Close()
+	out, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out), code
+}
+
+func TestRunGitConfigCommand(t *testing.T) {
+	out, code := runGitConfigCapture(t, "-platforms", "github")
+	want := "git config --global 'url.http://127.0.0.1:9090/https://github.com/.insteadOf' 'https://github.com/'\n" +
+		"git config --global 'url.https://github.com/.pushInsteadOf' 'https://github.com/'\n"
+	if code != 0 || out != want {
+		t.Errorf("默认监听地址: code = %d, out =\n%s", code, out)
+	}
+
+	out, code = runGitConfigCapture(t, "-base", "https://gh.example.com/", "-format", "gitconfig", "-platforms", "huggingface")
+	want = "[url \"https://gh.example.com/https://huggingface.co/\"]\n\tinsteadOf = \"https://huggingface.co/\"\n" +
+		"[url \"https://huggingface.co/\"]\n\tpushInsteadOf = \"https://huggingface.co/\"\n"
+	if code != 0 || out != want {
+		t.Errorf("-base: code = %d, out =\n%s", code, out)
+	}
+
+	if _, code = runGitConfigCapture(t, "-format", "yaml"); code != 1 {
+		t.Errorf("未知格式 code = %d", code)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitHub REST API 代理：api.github.com 的GET请求使用令牌池中的令牌访问上游，成功的响应保存在共享的内存缓存中，
+// 过期后用 If-None-Match 向上游重新验证（GitHub对304响应不计入速率限制）
+
+// 单个响应的缓存上限，更大的响应直接转发
+const maxGitHubAPICacheEntryBytes = 4 << 20
+
+// 随缓存保存并返回给客户端的上游响应头
+var githubAPICachedHeaders = []string{"Content-Type", "ETag", "Last-Modified", "Link", "X-GitHub-Media-Type"}
+
+// 速率限制响应头
+var githubRateLimitHeaders = []string{
+	"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "X-RateLimit-Used", "X-RateLimit-Resource",
+}
+
+// 缓存的API响应
+type apiCacheEntry struct {
+	key     string
+	header  http.Header
+	body    []byte
+	fetched time.Time
+}
+
+func (e *apiCacheEntry) size() int64 {
+	return int64(len(e.key) + len(e.body))
+}
+
+// 按最近使用淘汰的API响应缓存
+type apiCache struct {
+	mu    sync.Mutex
+	size  int64
+	lru   *list.List
+	items map[string]*list.Element
+}
+
+var githubAPICache = &apiCache{lru: list.New(), items: make(map[string]*list.Element)}
+
+func (c *apiCache) get(key string) *apiCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*apiCacheEntry)
+}
+
+// 写入缓存，超过大小上限时淘汰最久未使用的响应
+func (c *apiCache) put(e *apiCacheEntry, maxBytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[e.key]; ok {
+		c.remove(el)
+	}
+	if e.size() > maxBytes {
+		return
+	}
+	c.items[e.key] = c.lru.PushFront(e)
+	c.size += e.size()
+	for c.size > maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *apiCache) remove(el *list.Element) {
+	e := el.Value.(*apiCacheEntry)
+	c.lru.Remove(el)
+	delete(c.items, e.key)
+	c.size -= e.size()
+}
+
+// 服务端身份（令牌或匿名）最近一次的速率限制状态，返回缓存的响应时附带
+var githubRateLimit struct {
+	sync.Mutex
+	header http.Header
+}
+
+// 记录上游响应中的速率限制状态
+func recordGitHubRateLimit(h http.Header) {
+	if h.Get("X-RateLimit-Remaining") == "" {
+		return
+	}
+	state := make(http.Header)
+	for _, name := range githubRateLimitHeaders {
+		if value := h.Get(name); value != "" {
+			state.Set(name, value)
+		}
+	}
+	githubRateLimit.Lock()
+	githubRateLimit.header = state
+	githubRateLimit.Unlock()
+}
+
+func copyGitHubRateLimit(dst http.Header) {
+	githubRateLimit.Lock()
+	defer githubRateLimit.Unlock()
+	for name, values := range githubRateLimit.header {
+		dst[name] = values
+	}
+}
+
+// 是否是GitHub REST API 的读取请求
+func isGitHubAPIRead(method string, target *url.URL) bool {
+	return target.Host == "api.github.com" && (method == http.MethodGet || method == http.MethodHead)
+}
+
+// 代理GitHub REST API 的读取请求。客户端自带令牌的请求使用客户端的令牌，不经过共享缓存
+func serveGitHubAPI(w http.ResponseWriter, r *http.Request, target *url.URL) {
+	clientAuth := r.Header.Get("Authorization")
+	shared := clientAuth == ""
+	// 同一地址按Accept返回不同的媒体类型
+	key := r.Header.Get("Accept") + " " + r.Header.Get("X-GitHub-Api-Version") + " " + target.String()
+
+	var cached *apiCacheEntry
+	if shared {
+		cached = githubAPICache.get(key)
+		if cached != nil && time.Since(cached.fetched) < time.Duration(config.GitHubAPI.CacheTTL)*time.Second {
+			writeGitHubAPICached(w, r, cached, "HIT")
+			return
+		}
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, target.String(), nil)
+	if err != nil {
+		http.Error(w, "创建请求失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	applyHeaderProfile(req.Header, r.Header, headerProfileFor(target))
+	var token *upstreamToken
+	if shared {
+		// 共享缓存自己处理条件请求；缓存未压缩的内容，可以返回给任何客户端
+		for _, name := range cacheHeaders {
+			req.Header.Del(name)
+		}
+		req.Header.Del("Accept-Encoding")
+		if cached != nil && cached.header.Get("ETag") != "" {
+			req.Header.Set("If-None-Match", cached.header.Get("ETag"))
+		}
+		token = upstreamTokens.apply(req.Header, target)
+	} else {
+		req.Header.Set("Authorization", clientAuth)
+	}
+
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		if errors.Is(err, errEgressDenied) {
+			http.Error(w, "请求被出站策略拒绝: "+err.Error(), http.StatusForbidden)
+			return
+		}
+		http.Error(w, "请求失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer resp.Body.Close()
+	if shared {
+		upstreamTokens.record(token, resp)
+		recordGitHubRateLimit(resp.Header)
+	}
+
+	// 内容未变化：刷新缓存时间，返回缓存的内容
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		refreshed := &apiCacheEntry{key: key, header: cached.header.Clone(), body: cached.body, fetched: time.Now()}
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			refreshed.header.Set("ETag", etag)
+		}
+		githubAPICache.put(refreshed, config.GitHubAPI.CacheMaxBytes)
+		writeGitHubAPICached(w, r, refreshed, "REVALIDATED")
+		return
+	}
+
+	if shared && r.Method == http.MethodGet && resp.StatusCode == http.StatusOK {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxGitHubAPICacheEntryBytes+1))
+		if err == nil && len(body) <= maxGitHubAPICacheEntryBytes {
+			entry := &apiCacheEntry{key: key, header: make(http.Header), body: body, fetched: time.Now()}
+			for _, name := range githubAPICachedHeaders {
+				if values := resp.Header.Values(name); len(values) > 0 {
+					entry.header[http.CanonicalHeaderKey(name)] = values
+				}
+			}
+			githubAPICache.put(entry, config.GitHubAPI.CacheMaxBytes)
+			writeGitHubAPICached(w, r, entry, "MISS")
+			return
+		}
+		// 超过缓存上限：已读取的部分和剩余内容直接转发
+		resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), resp.Body))
+	}
+
+	removeHopByHopHeaders(resp.Header)
+	for key, values := range resp.Header {
+		w.Header()[key] = values
+	}
+	rewriteGitHubAPILink(r, w.Header())
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		log.Printf("复制响应体失败: %v", err)
+	}
+	log.Printf("[%s] %s -> %s (Status: %d)", r.RemoteAddr, r.RequestURI, target.String(), resp.StatusCode)
+}
+
+// 返回缓存的响应，客户端的 If-None-Match 与缓存的ETag一致时返回304
+func writeGitHubAPICached(w http.ResponseWriter, r *http.Request, e *apiCacheEntry, status string) {
+	h := w.Header()
+	for name, values := range e.header {
+		h[name] = values
+	}
+	copyGitHubRateLimit(h)
+	rewriteGitHubAPILink(r, h)
+	h.Set("X-Cache", status)
+
+	if etag := e.header.Get("ETag"); etag != "" && etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Length", strconv.Itoa(len(e.body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(e.body)
+	}
+	log.Printf("[%s] %s (GitHub API 缓存: %s)", r.RemoteAddr, r.RequestURI, status)
+}
+
+// If-None-Match 是否包含指定的ETag（弱比较）
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate != "" && strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// 把分页 Link 中的地址改写为经过代理的地址，客户端翻页时同样使用缓存
+func rewriteGitHubAPILink(r *http.Request, h http.Header) {
+	link := h.Get("Link")
+	if link == "" {
+		return
+	}
+	parts := strings.Split(link, ",")
+	for i, part := range parts {
+		target, params, ok := strings.Cut(strings.TrimSpace(part), ";")
+		if !ok {
+			continue
+		}
+		u, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+		if err != nil || checkEgressURL(u) != nil {
+			continue
+		}
+		if proxied, ok := proxyURLFor(r, u); ok {
+			parts[i] = "<" + proxied + ">;" + params
+		}
+	}
+	h.Set("Link", strings.Join(parts, ", "))
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"container/list"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch string
+		etag        string
+		want        bool
+	}{
+		{`"abc"`, `"abc"`, true},
+		{`W/"abc"`, `"abc"`, true},
+		{`"abc"`, `W/"abc"`, true},
+		{`"x", "abc"`, `"abc"`, true},
+		{`*`, `"abc"`, true},
+		{`"abcd"`, `"abc"`, false},
+		{``, `"abc"`, false},
+		{` , `, `"abc"`, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.ifNoneMatch, tt.etag); got != tt.want {
+			t.Errorf("etagMatches(%q, %q) = %v, want %v", tt.ifNoneMatch, tt.etag, got, tt.want)
+		}
+	}
+}
+
+// 使用独立的API缓存，测试结束后恢复
+func withGitHubAPICache(t *testing.T) {
+	t.Helper()
+	old := githubAPICache
+	githubAPICache = &apiCache{lru: list.New(), items: make(map[string]*list.Element)}
+	t.Cleanup(func() { githubAPICache = old })
+}
+
+// 模拟 api.github.com：内容的ETag为 etag，带相同 If-None-Match 的请求返回304
+type stubGitHubAPI struct {
+	etag     string
+	body     string
+	requests []*http.Request
+}
+
+func (s *stubGitHubAPI) roundTrip(r *http.Request) (*http.Response, error) {
+	s.requests = append(s.requests, r)
+	if r.URL.Path == "/markdown" {
+		return stubResponse(r, http.StatusOK, http.Header{"Content-Type": {"text/html"}}, "<p>hi</p>"), nil
+	}
+	h := http.Header{"Etag": {s.etag}, "X-Ratelimit-Remaining": {"59"}}
+	if r.Header.Get("If-None-Match") == s.etag {
+		return stubResponse(r, http.StatusNotModified, h, ""), nil
+	}
+	h.Set("Content-Type", "application/json")
+	return stubResponse(r, http.StatusOK, h, s.body), nil
+}
+
+func githubAPIRequest(method, target string, header http.Header, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/"+target, strings.NewReader(body))
+	r.Host = "proxy.local"
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	proxyHandler(w, r)
+	return w
+}
+
+func TestGitHubAPICache(t *testing.T) {
+	withGitHubAPICache(t)
+	withConfig(t, func(cfg *Config) { cfg.GitHubAPI.CacheTTL = 60 })
+	upstream := &stubGitHubAPI{etag: `"v1"`, body: `{"tag_name":"v1.0"}`}
+	stubUpstream(t, upstream.roundTrip)
+	const target = "https://api.github.com/repos/o/r/releases/latest"
+
+	// 首次请求从上游获取并缓存，之后在新鲜时间内直接返回缓存
+	for i, want := range []string{"MISS", "HIT"} {
+		w := githubAPIRequest("GET", target, nil, "")
+		if w.Code != http.StatusOK || w.Header().Get("X-Cache") != want || w.Body.String() != upstream.body {
+			t.Fatalf("请求 %d: status %d, X-Cache %q, body %q", i, w.Code, w.Header().Get("X-Cache"), w.Body.String())
+		}
+	}
+	if len(upstream.requests) != 1 {
+		t.Fatalf("上游请求 %d 次，want 1", len(upstream.requests))
+	}
+	if upstream.requests[0].Header.Get("If-None-Match") != "" {
+		t.Error("首次请求不应带 If-None-Match")
+	}
+
+	// 客户端的 If-None-Match 与缓存一致时返回304，不访问上游
+	w := githubAPIRequest("GET", target, http.Header{"If-None-Match": {`"v1"`}}, "")
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("条件请求: status %d, X-Cache %q, body %q", w.Code, w.Header().Get("X-Cache"), w.Body.String())
+	}
+	if w.Header().Get("X-Ratelimit-Remaining") != "59" {
+		t.Errorf("缓存的响应应附带速率限制状态: %v", w.Header())
+	}
+	if len(upstream.requests) != 1 {
+		t.Errorf("上游请求 %d 次，want 1", len(upstream.requests))
+	}
+}
+
+func TestGitHubAPIRevalidate(t *testing.T) {
+	withGitHubAPICache(t)
+	// 新鲜时间为0：每次都向上游重新验证
+	withConfig(t, func(cfg *Config) { cfg.GitHubAPI.CacheTTL = 0 })
+	upstream := &stubGitHubAPI{etag: `"v1"`, body: `{"tag_name":"v1.0"}`}
+	stubUpstream(t, upstream.roundTrip)
+	const target = "https://api.github.com/repos/o/r/releases/latest"
+
+	githubAPIRequest("GET", target, nil, "")
+
+	// 内容未变化：上游返回304，客户端收到缓存的完整内容
+	w := githubAPIRequest("GET", target, nil, "")
+	last := upstream.requests[len(upstream.requests)-1]
+	if last.Header.Get("If-None-Match") != `"v1"` {
+		t.Errorf("重新验证的 If-None-Match = %q", last.Header.Get("If-None-Match"))
+	}
+	if w.Code != http.StatusOK || w.Header().Get("X-Cache") != "REVALIDATED" || w.Body.String() != upstream.body {
+		t.Errorf("重新验证: status %d, X-Cache %q, body %q", w.Code, w.Header().Get("X-Cache"), w.Body.String())
+	}
+
+	// 内容变化：上游返回新内容，替换缓存
+	upstream.etag, upstream.body = `"v2"`, `{"tag_name":"v2.0"}`
+	w = githubAPIRequest("GET", target, nil, "")
+	if w.Code != http.StatusOK || w.Header().Get("X-Cache") != "MISS" || w.Body.String() != upstream.body {
+		t.Errorf("内容变化: status %d, X-Cache %q, body %q", w.Code, w.Header().Get("X-Cache"), w.Body.String())
+	}
+	w = githubAPIRequest("GET", target, nil, "")
+	if w.Header().Get("X-Cache") != "REVALIDATED" || w.Header().Get("Etag") != `"v2"` || w.Body.String() != upstream.body {
+		t.Errorf("替换后的缓存: X-Cache %q, ETag %q, body %q", w.Header().Get("X-Cache"), w.Header().Get("Etag"), w.Body.String())
+	}
+}
+
+func TestGitHubAPINotCached(t *testing.T) {
+	withGitHubAPICache(t)
+	withConfig(t, func(cfg *Config) { cfg.GitHubAPI.CacheTTL = 60 })
+	upstream := &stubGitHubAPI{etag: `"v1"`, body: `{"id":1}`}
+	stubUpstream(t, upstream.roundTrip)
+
+	// 只读的POST接口转发但不缓存
+	for i := 0; i < 2; i++ {
+		w := githubAPIRequest("POST", "https://api.github.com/markdown", http.Header{"Content-Type": {"application/json"}}, `{"text":"hi"}`)
+		if w.Code != http.StatusOK || w.Header().Get("X-Cache") != "" {
+			t.Errorf("POST /markdown: status %d, X-Cache %q", w.Code, w.Header().Get("X-Cache"))
+		}
+	}
+	if len(upstream.requests) != 2 {
+		t.Errorf("POST /markdown 上游请求 %d 次，want 2", len(upstream.requests))
+	}
+
+	// 其他写入接口和方法被拒绝，不访问上游
+	upstream.requests = nil
+	for _, method := range []string{"POST", "PATCH", "DELETE"} {
+		if w := githubAPIRequest(method, "https://api.github.com/repos/o/r/issues", nil, `{}`); w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s /repos/o/r/issues: status %d", method, w.Code)
+		}
+	}
+	if len(upstream.requests) != 0 {
+		t.Errorf("被拒绝的请求访问了上游 %d 次", len(upstream.requests))
+	}
+
+	// HEAD和客户端自带令牌的请求不写入共享缓存
+	const target = "https://api.github.com/repos/o/r"
+	githubAPIRequest("HEAD", target, nil, "")
+	w := githubAPIRequest("GET", target, http.Header{"Authorization": {"Bearer client"}}, "")
+	if w.Code != http.StatusOK || w.Header().Get("X-Cache") != "" {
+		t.Errorf("自带令牌: status %d, X-Cache %q", w.Code, w.Header().Get("X-Cache"))
+	}
+	if got := upstream.requests[len(upstream.requests)-1].Header.Get("Authorization"); got != "Bearer client" {
+		t.Errorf("自带令牌的请求应使用客户端的令牌，实际 %q", got)
+	}
+	if w := githubAPIRequest("GET", target, nil, ""); w.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("HEAD和自带令牌的请求不应写入缓存，X-Cache = %q", w.Header().Get("X-Cache"))
+	}
+}
@@ -0,0 +1,1093 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Go模块代理：实现GOPROXY协议，从GitHub、GitLab的源码归档生成模块zip，已发布的版本永久缓存
+// GOPROXY=<代理地址>/goproxy,direct
+
+const (
+	// 模块zip解压后的大小上限，与go命令一致；源码归档使用同样的上限
+	maxGoModuleBytes = 500 << 20
+	// go.mod 和 LICENSE 的大小上限
+	maxGoModFileBytes = 16 << 20
+	// 伪版本中的时间格式
+	goPseudoTimeFormat = "20060102150405"
+	// 确定伪版本的基础版本时最多检查的标签数
+	maxPseudoBaseChecks = 5
+)
+
+var (
+	// 规范的语义化版本，不含构建元数据
+	goSemverPattern = regexp.MustCompile(`^v(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-(?:0|[1-9]\d*|\d*[A-Za-z-][0-9A-Za-z-]*)(?:\.(?:0|[1-9]\d*|\d*[A-Za-z-][0-9A-Za-z-]*))*)?$`)
+	// 伪版本：vX.0.0-时间-提交、vX.Y.Z-pre.0.时间-提交、vX.Y.(Z+1)-0.时间-提交
+	goPseudoPattern = regexp.MustCompile(`^v[0-9]+\.(?:0\.0-|\d+\.\d+-(?:[^+]*\.)?0\.)(\d{14})-([0-9a-f]{12})$`)
+	// 模块路径末尾的主版本后缀，如 /v2
+	goMajorPattern = regexp.MustCompile(`^v(?:[2-9]|[1-9]\d+)$`)
+	// 模块路径的一段
+	goPathElemPattern = regexp.MustCompile(`^[A-Za-z0-9._~-]+$`)
+	// 分支名、标签名或提交SHA查询
+	goQueryPattern  = regexp.MustCompile(`^[A-Za-z0-9._~+-]+$`)
+	goCommitPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+	// ?go-get=1 页面中的 go-import 元标签
+	goImportPattern = regexp.MustCompile(`<meta\s+name="go-import"\s+content="([^"]*)"`)
+)
+
+// 模块或版本不存在，返回404让go命令尝试GOPROXY中的下一项
+var errGoNotFound = errors.New("模块或版本不存在")
+
+// Windows保留的文件名，模块中不允许出现
+var goWindowsReservedNames = []string{
+	"CON", "PRN", "AUX", "NUL",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9",
+}
+
+// 版本信息（.info 和 @latest 的响应）
+type goVersionInfo struct {
+	Version string
+	Time    time.Time
+}
+
+// 模块及其所在的仓库
+type goModule struct {
+	Path string
+	Repo *SourceURL
+	// 模块在仓库中的目录，也是版本标签的前缀，如 tools（标签为 tools/v1.2.0）
+	Dir string
+	// 主版本后缀，如 v2；v0、v1为空
+	Major string
+}
+
+// 模块缓存目录：<目录>/<转义后的模块路径>/@v/<版本>.info|.mod|.zip，与go命令的下载缓存结构相同
+var goProxyCacheDir string
+
+// 初始化模块缓存目录
+func setupGoProxyCache(cfg GoProxyConfig) error {
+	dir := cfg.CacheDir
+	if dir == "" {
+		dir = defaultGoProxyCacheDir()
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "tmp"), 0o755); err != nil {
+		return err
+	}
+	goProxyCacheDir = dir
+	return nil
+}
+
+func defaultGoProxyCacheDir() string {
+	return filepath.Join(os.TempDir(), "ghproxy-goproxy")
+}
+
+// Go模块代理入口
+// GET /goproxy/<模块>/@v/list、/@v/<版本>.info、/@v/<版本>.mod、/@v/<版本>.zip、/goproxy/<模块>/@latest
+func goProxyHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("收到Go模块请求: %s", r.RequestURI)
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "不支持的请求方法: "+r.Method, http.StatusMethodNotAllowed)
+		return
+	}
+
+	rest := strings.TrimPrefix(r.URL.Path, "/goproxy/")
+	escapedPath, file, ok := strings.Cut(rest, "/@v/")
+	latest := false
+	if !ok {
+		escapedPath, latest = strings.CutSuffix(rest, "/@latest")
+	}
+	if !ok && !latest {
+		http.Error(w, "不支持的路径", http.StatusNotFound)
+		return
+	}
+	modPath, err := unescapeGoPath(escapedPath)
+	if err == nil {
+		err = checkGoModulePath(modPath)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// 已缓存的版本直接返回，不再访问上游
+	ext := path.Ext(file)
+	version, err := unescapeGoPath(strings.TrimSuffix(file, ext))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	canonical := !latest && goSemverPattern.MatchString(version)
+	if canonical && serveGoCached(w, r, modPath, version, ext, true) {
+		return
+	}
+
+	mod, err := resolveGoModule(r.Context(), modPath)
+	if err != nil {
+		goProxyError(w, err)
+		return
+	}
+
+	switch {
+	case latest:
+		version, err = mod.latest(r.Context())
+		ext = ".info"
+	case file == "list":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		versions, err := mod.versions(r.Context())
+		if err != nil {
+			goProxyError(w, err)
+			return
+		}
+		for _, v := range versions {
+			fmt.Fprintln(w, v)
+		}
+		return
+	case ext != ".info" && ext != ".mod" && ext != ".zip":
+		http.Error(w, "不支持的路径", http.StatusNotFound)
+		return
+	case canonical:
+		err = mod.ensure(r.Context(), version, ext)
+	case ext == ".info" && goQueryPattern.MatchString(version):
+		// 分支名、标签名或提交SHA，解析为对应的版本
+		version, err = mod.query(r.Context(), version)
+	default:
+		err = fmt.Errorf("%w: 无效的版本 %q", errGoNotFound, version)
+	}
+	if err != nil {
+		goProxyError(w, err)
+		return
+	}
+	if !serveGoCached(w, r, modPath, version, ext, canonical) {
+		http.Error(w, "读取模块缓存失败", http.StatusInternalServerError)
+	}
+}
+
+// 不存在的模块或版本返回404，其他错误返回502
+func goProxyError(w http.ResponseWriter, err error) {
+	log.Printf("Go模块请求失败: %v", err)
+	if errors.Is(err, errGoNotFound) || errors.Is(err, errRepoNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, "请求上游失败: "+err.Error(), http.StatusBadGateway)
+}
+
+// 缓存文件路径
+func goCachePath(modPath, version, ext string) string {
+	return filepath.Join(goProxyCacheDir, filepath.FromSlash(escapeGoPath(modPath)), "@v", escapeGoPath(version)+ext)
+}
+
+// 从缓存返回版本文件，未命中时返回false。immutable表示请求地址中是确定的版本（不是 @latest 或分支名）
+func serveGoCached(w http.ResponseWriter, r *http.Request, modPath, version, ext string, immutable bool) bool {
+	f, err := os.Open(goCachePath(modPath, version, ext))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	switch ext {
+	case ".info":
+		w.Header().Set("Content-Type", "application/json")
+	case ".mod":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	case ".zip":
+		w.Header().Set("Content-Type", "application/zip")
+	}
+	if immutable {
+		// 已发布版本的内容不会变化
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	http.ServeContent(w, r, "", info.ModTime(), f)
+	return true
+}
+
+// 写入缓存文件：先写临时文件再重命名，读取方不会看到写了一半的文件
+func goCacheWrite(file string, write func(io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Join(goProxyCacheDir, "tmp"), "write-")
+	if err != nil {
+		return err
+	}
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// 正在进行的构建，done关闭后version、err为构建结果
+type goBuild struct {
+	done    chan struct{}
+	version string
+	err     error
+}
+
+// 正在构建的版本，同一版本同时只构建一次
+var goBuilds = struct {
+	sync.Mutex
+	inflight map[string]*goBuild
+}{inflight: make(map[string]*goBuild)}
+
+// 执行构建并返回生成的版本，已有相同的构建在进行时等待其完成并返回它的结果。
+// 进行中的构建因发起请求被取消而失败时，等待方重新构建
+func goBuildOnce(ctx context.Context, key string, build func() (string, error)) (string, error) {
+	for {
+		goBuilds.Lock()
+		b, ok := goBuilds.inflight[key]
+		if !ok {
+			b = &goBuild{done: make(chan struct{})}
+			goBuilds.inflight[key] = b
+			goBuilds.Unlock()
+
+			defer func() {
+				goBuilds.Lock()
+				delete(goBuilds.inflight, key)
+				goBuilds.Unlock()
+				close(b.done)
+			}()
+			b.version, b.err = build()
+			return b.version, b.err
+		}
+		goBuilds.Unlock()
+
+		select {
+		case <-b.done:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		if !errors.Is(b.err, context.Canceled) && !errors.Is(b.err, context.DeadlineExceeded) {
+			return b.version, b.err
+		}
+	}
+}
+
+// 按提交生成伪版本的构建key。伪版本中的提交SHA为12位，@latest、分支名查询解析到完整SHA，
+// 统一取前12位，同一提交的请求共用一次构建
+func goCommitBuildKey(modPath, commit string) string {
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	return modPath + "@" + commit
+}
+
+// 按模块路径确定所在的仓库、目录和主版本，只支持已启用平台的 github.com、gitlab.com
+func resolveGoModule(ctx context.Context, modPath string) (*goModule, error) {
+	elems := strings.Split(modPath, "/")
+	host := elems[0]
+	if !isSupportedDomain(host) {
+		return nil, fmt.Errorf("%w: 不支持的模块 %s", errGoNotFound, modPath)
+	}
+
+	repoElems := 3
+	platform := platformGitHub
+	switch host {
+	case "github.com":
+	case "gitlab.com":
+		platform = platformGitLab
+		n, err := discoverGitLabRepo(ctx, modPath)
+		if err != nil {
+			return nil, err
+		}
+		repoElems = n
+	default:
+		return nil, fmt.Errorf("%w: 只支持 github.com、gitlab.com 上的模块", errGoNotFound)
+	}
+	if len(elems) < repoElems {
+		return nil, fmt.Errorf("%w: 无效的模块路径 %s", errGoNotFound, modPath)
+	}
+
+	mod := &goModule{
+		Path: modPath,
+		Repo: &SourceURL{
+			Platform:  platform,
+			Host:      host,
+			Namespace: strings.Join(elems[1:repoElems-1], "/"),
+			Repo:      elems[repoElems-1],
+			Kind:      SourceRepo,
+		},
+	}
+	rest := elems[repoElems:]
+	if n := len(rest); n > 0 && goMajorPattern.MatchString(rest[n-1]) {
+		mod.Major = rest[n-1]
+		rest = rest[:n-1]
+	}
+	mod.Dir = strings.Join(rest, "/")
+	return mod, nil
+}
+
+// GitLab的组可以多级嵌套，通过 ?go-get=1 页面的 go-import 元标签确定项目路径，返回项目路径的段数
+func discoverGitLabRepo(ctx context.Context, modPath string) (int, error) {
+	var page bytes.Buffer
+	if err := goFetch(ctx, "https://"+escapePathSegments(modPath)+"?go-get=1", 1<<20, &page); err != nil {
+		return 0, err
+	}
+	for _, m := range goImportPattern.FindAllSubmatch(page.Bytes(), -1) {
+		fields := strings.Fields(string(m[1]))
+		if len(fields) != 3 || fields[1] != "git" {
+			continue
+		}
+		if prefix := fields[0]; modPath == prefix || strings.HasPrefix(modPath, prefix+"/") {
+			if n := strings.Count(prefix, "/") + 1; n >= 3 {
+				return n, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%w: 无法确定 %s 所在的GitLab项目", errGoNotFound, modPath)
+}
+
+// 请求上游并把响应写入w，超过limit字节时返回错误；404、410 返回 errGoNotFound
+func goFetch(ctx context.Context, rawURL string, limit int64, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "ghproxy/"+Version)
+
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return fmt.Errorf("%w: %s 返回 %s", errGoNotFound, rawURL, resp.Status)
+	default:
+		return fmt.Errorf("%s 返回 %s", rawURL, resp.Status)
+	}
+	n, err := io.Copy(w, io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return err
+	}
+	if n > limit {
+		return fmt.Errorf("%w: %s 超过 %d 字节", errGoNotFound, rawURL, limit)
+	}
+	return nil
+}
+
+// 仓库的ref列表
+func (m *goModule) refs(ctx context.Context) (map[string]string, error) {
+	return repoRefs.list(ctx, m.Repo.CloneURL())
+}
+
+// 模块的版本标签：版本号 -> 提交SHA。子目录中的模块只取带目录前缀的标签
+func (m *goModule) tags(refs map[string]string) map[string]string {
+	prefix := "refs/tags/"
+	if m.Dir != "" {
+		prefix += m.Dir + "/"
+	}
+	tags := make(map[string]string)
+	for name, sha := range refs {
+		v, ok := strings.CutPrefix(name, prefix)
+		if ok && goSemverPattern.MatchString(v) && !goPseudoPattern.MatchString(v) && m.majorOK(v) {
+			tags[v] = sha
+		}
+	}
+	return tags
+}
+
+// 版本的主版本号是否与模块路径一致：没有后缀的模块只能是v0、v1
+func (m *goModule) majorOK(version string) bool {
+	major, _, _ := strings.Cut(version, ".")
+	if m.Major == "" {
+		return major == "v0" || major == "v1"
+	}
+	return major == m.Major
+}
+
+// 按版本号排序的版本列表（/@v/list）
+func (m *goModule) versions(ctx context.Context) ([]string, error) {
+	refs, err := m.refs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for v := range m.tags(refs) {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return compareSemver(versions[i], versions[j]) < 0 })
+	return versions, nil
+}
+
+// 最新版本（/@latest）：优先取最高的正式版本，其次是预发布版本，没有版本标签时为默认分支最新提交的伪版本
+func (m *goModule) latest(ctx context.Context) (string, error) {
+	refs, err := m.refs(ctx)
+	if err != nil {
+		return "", err
+	}
+	release, prerelease := "", ""
+	for v := range m.tags(refs) {
+		best := &release
+		if strings.Contains(v, "-") {
+			best = &prerelease
+		}
+		if *best == "" || compareSemver(v, *best) > 0 {
+			*best = v
+		}
+	}
+	switch {
+	case release != "":
+		return release, m.ensure(ctx, release, ".info")
+	case prerelease != "":
+		return prerelease, m.ensure(ctx, prerelease, ".info")
+	case refs["HEAD"] != "":
+		return m.commitVersion(ctx, refs, refs["HEAD"])
+	}
+	return "", fmt.Errorf("%w: 仓库没有默认分支", errGoNotFound)
+}
+
+// 把分支名、标签名或提交SHA解析为版本
+func (m *goModule) query(ctx context.Context, query string) (string, error) {
+	refs, err := m.refs(ctx)
+	if err != nil {
+		return "", err
+	}
+	var commit string
+	switch {
+	case refs["refs/heads/"+query] != "":
+		commit = refs["refs/heads/"+query]
+	case refs["refs/tags/"+query] != "":
+		commit = refs["refs/tags/"+query]
+	case goCommitPattern.MatchString(query):
+		commit = query
+	default:
+		return "", fmt.Errorf("%w: 没有名为 %s 的分支、标签或提交", errGoNotFound, query)
+	}
+	return m.commitVersion(ctx, refs, commit)
+}
+
+// 提交对应的版本：提交上有模块的版本标签时使用标签，否则生成伪版本
+func (m *goModule) commitVersion(ctx context.Context, refs map[string]string, commit string) (string, error) {
+	best := ""
+	for v, sha := range m.tags(refs) {
+		if strings.HasPrefix(sha, commit) && (best == "" || compareSemver(v, best) > 0) {
+			best = v
+		}
+	}
+	if best != "" {
+		return best, m.ensure(ctx, best, ".info")
+	}
+	base := m.pseudoBase(ctx, refs, commit)
+	return goBuildOnce(ctx, goCommitBuildKey(m.Path, commit), func() (string, error) {
+		return m.build(ctx, commit, "", base)
+	})
+}
+
+// 伪版本的基础版本：提交之前最近的版本标签，与go命令直连时生成的伪版本一致。
+// 按版本从高到低通过上游API检查标签是否是提交的祖先，查询失败时不使用基础版本（生成的 vN.0.0 伪版本同样有效）
+func (m *goModule) pseudoBase(ctx context.Context, refs map[string]string, commit string) string {
+	tags := m.tags(refs)
+	var versions []string
+	for v := range tags {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return compareSemver(versions[i], versions[j]) > 0 })
+
+	for i, v := range versions {
+		if i >= maxPseudoBaseChecks {
+			break
+		}
+		ok, err := m.isAncestor(ctx, tags[v], commit)
+		if err != nil {
+			log.Printf("检查 %s 是否是 %s 的祖先失败: %v", v, commit, err)
+			return ""
+		}
+		if ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// 通过上游API检查ancestor是否是commit的祖先
+func (m *goModule) isAncestor(ctx context.Context, ancestor, commit string) (bool, error) {
+	if m.Repo.Platform == platformGitLab {
+		apiURL := fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/repository/merge_base?refs[]=%s&refs[]=%s",
+			url.PathEscape(m.Repo.RepoID()), ancestor, commit)
+		var result struct {
+			ID string `json:"id"`
+		}
+		if err := fetchUpstreamJSON(ctx, apiURL, &result); err != nil {
+			return false, err
+		}
+		return result.ID == ancestor, nil
+	}
+
+	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/compare/%s...%s?per_page=1",
+		url.PathEscape(m.Repo.Namespace), url.PathEscape(m.Repo.Repo), ancestor, commit)
+	var result struct {
+		Status string `json:"status"`
+	}
+	if err := fetchUpstreamJSON(ctx, apiURL, &result); err != nil {
+		return false, err
+	}
+	return result.Status == "ahead" || result.Status == "identical", nil
+}
+
+// 生成伪版本：基础版本为正式版本时补丁号加一（vX.Y.(Z+1)-0.时间-提交），为预发布版本时追加 .0（vX.Y.Z-pre.0.时间-提交），
+// 没有基础版本时为 vN.0.0-时间-提交
+func goPseudoVersion(major, base string, t time.Time, commit string) string {
+	suffix := t.Format(goPseudoTimeFormat) + "-" + commit[:12]
+	switch {
+	case base == "":
+		if major == "" {
+			major = "v0"
+		}
+		return major + ".0.0-" + suffix
+	case strings.Contains(base, "-"):
+		return base + ".0." + suffix
+	}
+	i := strings.LastIndex(base, ".")
+	patch, _ := strconv.Atoi(base[i+1:])
+	return base[:i+1] + strconv.Itoa(patch+1) + "-0." + suffix
+}
+
+// 确保版本文件已在缓存中。已发布版本的 .mod 只读取单个go.mod，避免为依赖图中的每个版本下载整个归档
+func (m *goModule) ensure(ctx context.Context, version, ext string) error {
+	if _, err := os.Stat(goCachePath(m.Path, version, ext)); err == nil {
+		return nil
+	}
+	if !m.majorOK(version) {
+		return fmt.Errorf("%w: 版本 %s 与模块路径的主版本不一致", errGoNotFound, version)
+	}
+
+	// 标签版本按版本号构建，伪版本按提交构建
+	var commit string
+	key := m.Path + "@" + version
+	if match := goPseudoPattern.FindStringSubmatch(version); match != nil {
+		commit = match[2]
+		key = goCommitBuildKey(m.Path, commit)
+	} else {
+		refs, err := m.refs(ctx)
+		if err != nil {
+			return err
+		}
+		if commit = m.tags(refs)[version]; commit == "" {
+			return fmt.Errorf("%w: 标签 %s 不存在", errGoNotFound, path.Join(m.Dir, version))
+		}
+		if ext == ".mod" {
+			return m.fetchGoMod(ctx, version, commit)
+		}
+	}
+
+	build := func() (string, error) {
+		return m.build(ctx, commit, version, "")
+	}
+	built, err := goBuildOnce(ctx, key, build)
+	if err == nil && built != version {
+		// 共用的构建按其他基础版本生成了伪版本，按请求的版本单独构建
+		_, err = goBuildOnce(ctx, m.Path+"@"+version, build)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(goCachePath(m.Path, version, ext)); err != nil {
+		return fmt.Errorf("构建 %s@%s 失败", m.Path, version)
+	}
+	return nil
+}
+
+// 直接读取版本的go.mod并写入缓存
+func (m *goModule) fetchGoMod(ctx context.Context, version, commit string) error {
+	_, gomod, err := m.findDir(func(name string) ([]byte, bool, error) {
+		file := *m.Repo
+		file.Kind = SourceFile
+		file.Ref = commit
+		file.FilePath = name
+		var buf bytes.Buffer
+		err := goFetch(ctx, file.RawURL(), maxGoModFileBytes, &buf)
+		if errors.Is(err, errGoNotFound) {
+			return nil, false, nil
+		}
+		return buf.Bytes(), err == nil, err
+	})
+	if err != nil {
+		return err
+	}
+	return goCacheWrite(goCachePath(m.Path, version, ".mod"), func(w io.Writer) error {
+		_, err := w.Write(gomod)
+		return err
+	})
+}
+
+// 按go命令的规则确定模块在仓库中的目录和go.mod内容：带主版本后缀的模块可以位于 <目录>/vN 或 <目录>，
+// 两处只能有一处声明该主版本；没有go.mod的仓库只能作为根目录下的v0、v1模块
+func (m *goModule) findDir(read func(name string) ([]byte, bool, error)) (string, []byte, error) {
+	file1 := path.Join(m.Dir, "go.mod")
+	gomod1, found1, err := read(file1)
+	if err != nil {
+		return "", nil, err
+	}
+	mpath1 := goModFilePath(gomod1)
+
+	if m.Major != "" {
+		dir2 := path.Join(m.Dir, m.Major)
+		file2 := path.Join(dir2, "go.mod")
+		gomod2, found2, err := read(file2)
+		if err != nil {
+			return "", nil, err
+		}
+		if found2 {
+			mpath2 := goModFilePath(gomod2)
+			if mpath2 == "" || goPathMajor(mpath2) != m.Major {
+				return "", nil, fmt.Errorf("%w: %s 中的模块路径 %q 与主版本 %s 不一致", errGoNotFound, file2, mpath2, m.Major)
+			}
+			if found1 && goPathMajor(mpath1) == m.Major {
+				return "", nil, fmt.Errorf("%w: %s 和 %s 都声明了主版本 %s", errGoNotFound, file1, file2, m.Major)
+			}
+			return dir2, gomod2, nil
+		}
+	}
+
+	if found1 {
+		if mpath1 == "" || goPathMajor(mpath1) != m.Major {
+			return "", nil, fmt.Errorf("%w: %s 中的模块路径 %q 与 %s 不一致", errGoNotFound, file1, mpath1, m.Path)
+		}
+		return m.Dir, gomod1, nil
+	}
+	if m.Dir == "" && m.Major == "" {
+		// 没有go.mod的旧仓库，与go命令一样生成只有module指令的go.mod
+		return "", []byte("module " + m.Path + "\n"), nil
+	}
+	return "", nil, fmt.Errorf("%w: 缺少 %s", errGoNotFound, file1)
+}
+
+// 源码归档中的普通文件，路径相对仓库根目录
+type goArchive struct {
+	files map[string]*zip.File
+	// 提交时间
+	time time.Time
+}
+
+// 读取源码归档：所有文件都在同一个顶层目录下（如 repo-<sha>/），符号链接等非普通文件不计入
+func newGoArchive(zr *zip.Reader) (*goArchive, error) {
+	a := &goArchive{files: make(map[string]*zip.File)}
+	prefix := ""
+	for _, f := range zr.File {
+		if prefix == "" {
+			i := strings.Index(f.Name, "/")
+			if i <= 0 {
+				return nil, fmt.Errorf("源码归档的目录结构无效")
+			}
+			prefix = f.Name[:i+1]
+		}
+		name, ok := strings.CutPrefix(f.Name, prefix)
+		if !ok {
+			return nil, fmt.Errorf("源码归档的目录结构无效")
+		}
+		if name == "" || strings.HasSuffix(name, "/") || !f.Mode().IsRegular() {
+			continue
+		}
+		a.files[name] = f
+		if a.time.IsZero() {
+			a.time = f.Modified.UTC()
+		}
+	}
+	if len(a.files) == 0 {
+		return nil, fmt.Errorf("源码归档为空")
+	}
+	return a, nil
+}
+
+// 读取归档中的文件
+func (a *goArchive) read(name string) ([]byte, bool, error) {
+	f, ok := a.files[name]
+	if !ok {
+		return nil, false, nil
+	}
+	if f.UncompressedSize64 > maxGoModFileBytes {
+		return nil, false, fmt.Errorf("%w: %s 超过 %d 字节", errGoNotFound, name, maxGoModFileBytes)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, false, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	return data, err == nil, err
+}
+
+// 源码归档地址，按提交SHA下载
+func (m *goModule) archiveURL(commit string) string {
+	if m.Repo.Platform == platformGitLab {
+		return m.Repo.RepoURL() + "/-/archive/" + commit + "/" + m.Repo.Repo + "-" + commit + ".zip"
+	}
+	return "https://codeload.github.com/" + escapePathSegments(m.Repo.RepoID()) + "/zip/" + commit
+}
+
+// 下载提交的源码归档，生成版本的 .mod、.zip、.info 并写入缓存，返回版本号。version为空时按提交和基础版本生成伪版本
+func (m *goModule) build(ctx context.Context, commit, version, base string) (string, error) {
+	archive, err := os.CreateTemp(filepath.Join(goProxyCacheDir, "tmp"), "archive-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(archive.Name())
+	err = goFetch(ctx, m.archiveURL(commit), maxGoModuleBytes, archive)
+	if closeErr := archive.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("下载源码归档失败: %w", err)
+	}
+
+	zr, err := zip.OpenReader(archive.Name())
+	if err != nil {
+		return "", fmt.Errorf("无效的源码归档: %w", err)
+	}
+	defer zr.Close()
+	a, err := newGoArchive(&zr.Reader)
+	if err != nil {
+		return "", err
+	}
+	// git archive 把提交SHA写在zip注释中，用于补全短SHA
+	if sha := strings.TrimSpace(zr.Comment); isCommitSHA(sha) && strings.HasPrefix(sha, commit) {
+		commit = sha
+	}
+
+	if match := goPseudoPattern.FindStringSubmatch(version); match != nil && match[1] != a.time.Format(goPseudoTimeFormat) {
+		return "", fmt.Errorf("%w: 伪版本 %s 的时间与提交时间 %s 不一致", errGoNotFound, version, a.time.Format(time.RFC3339))
+	}
+	if version == "" {
+		if len(commit) < 12 {
+			return "", fmt.Errorf("%w: 无法确定提交 %s 的完整SHA", errGoNotFound, commit)
+		}
+		version = goPseudoVersion(m.Major, base, a.time, commit)
+	}
+
+	dir, gomod, err := m.findDir(a.read)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("生成Go模块: %s@%s（提交 %s）", m.Path, version, commit)
+
+	if err := goCacheWrite(goCachePath(m.Path, version, ".mod"), func(w io.Writer) error {
+		_, err := w.Write(gomod)
+		return err
+	}); err != nil {
+		return "", err
+	}
+	if err := goCacheWrite(goCachePath(m.Path, version, ".zip"), func(w io.Writer) error {
+		return m.writeZip(w, version, dir, a)
+	}); err != nil {
+		return "", err
+	}
+	// .info 最后写入
+	if err := goCacheWrite(goCachePath(m.Path, version, ".info"), func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(goVersionInfo{Version: version, Time: a.time})
+	}); err != nil {
+		return "", err
+	}
+	return version, nil
+}
+
+// 按 golang.org/x/mod/zip 的规则生成模块zip，内容与go命令生成的一致，go.sum中的校验和可以通过校验：
+// 排除嵌套模块、vendor目录中的包和非普通文件，文件名不合法或大小写冲突时报错；子目录中的模块没有LICENSE时使用仓库根目录的LICENSE
+func (m *goModule) writeZip(w io.Writer, version, dir string, a *goArchive) error {
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+	var names []string
+	nested := make(map[string]bool)
+	for name := range a.files {
+		rel, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		names = append(names, rel)
+		if d, base := path.Split(rel); base == "go.mod" && d != "" {
+			nested[path.Clean(d)] = true
+		}
+	}
+	sort.Strings(names)
+
+	zw := zip.NewWriter(w)
+	var total uint64
+	add := func(name string, f *zip.File) error {
+		if total += f.UncompressedSize64; total > maxGoModuleBytes {
+			return fmt.Errorf("%w: 模块超过 %d 字节", errGoNotFound, maxGoModuleBytes)
+		}
+		if (name == "go.mod" || name == "LICENSE") && f.UncompressedSize64 > maxGoModFileBytes {
+			return fmt.Errorf("%w: %s 超过 %d 字节", errGoNotFound, name, maxGoModFileBytes)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		dst, err := zw.CreateHeader(&zip.FileHeader{Name: m.Path + "@" + version + "/" + name, Method: zip.Deflate})
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(dst, rc)
+		return err
+	}
+
+	seen := make(map[string]string)
+	haveLicense := false
+	for _, name := range names {
+		if isGoVendoredPackage(name) || inGoSubmodule(name, nested) || name == ".hg_archival.txt" {
+			continue
+		}
+		if err := checkGoFilePath(name); err != nil {
+			return fmt.Errorf("%w: 文件 %q 不能放入模块: %v", errGoNotFound, name, err)
+		}
+		lower := strings.ToLower(name)
+		if lower == "go.mod" && name != "go.mod" {
+			return fmt.Errorf("%w: 文件 %q 与go.mod只有大小写不同", errGoNotFound, name)
+		}
+		if other, ok := seen[lower]; ok {
+			return fmt.Errorf("%w: 文件 %q 和 %q 只有大小写不同", errGoNotFound, other, name)
+		}
+		seen[lower] = name
+		if name == "LICENSE" {
+			haveLicense = true
+		}
+		if err := add(name, a.files[prefix+name]); err != nil {
+			return err
+		}
+	}
+	if f, ok := a.files["LICENSE"]; ok && !haveLicense && dir != "" {
+		if err := add("LICENSE", f); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// vendor目录中的包不放入模块，vendor目录下的文件（如 vendor/modules.txt）保留。
+// 与go命令保持一致，包括非顶层vendor目录的偏移计算（go命令为了不改变已有模块的校验和保留了这个行为）
+func isGoVendoredPackage(name string) bool {
+	var i int
+	if strings.HasPrefix(name, "vendor/") {
+		i += len("vendor/")
+	} else if j := strings.Index(name, "/vendor/"); j >= 0 {
+		i += len("/vendor/")
+	} else {
+		return false
+	}
+	return strings.Contains(name[i:], "/")
+}
+
+// 文件是否属于嵌套的模块（所在目录或上级目录有go.mod）
+func inGoSubmodule(name string, nested map[string]bool) bool {
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if nested[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// 检查模块中的文件路径，规则与go命令一致
+func checkGoFilePath(name string) error {
+	for _, elem := range strings.Split(name, "/") {
+		if strings.Trim(elem, ".") == "" {
+			return fmt.Errorf("无效的路径段 %q", elem)
+		}
+		if strings.HasSuffix(elem, ".") {
+			return fmt.Errorf("路径段 %q 以点结尾", elem)
+		}
+		for _, r := range elem {
+			if !goFileNameRuneOK(r) {
+				return fmt.Errorf("包含不允许的字符 %q", r)
+			}
+		}
+		short, _, _ := strings.Cut(elem, ".")
+		for _, bad := range goWindowsReservedNames {
+			if strings.EqualFold(bad, short) {
+				return fmt.Errorf("%q 是Windows保留的文件名", short)
+			}
+		}
+	}
+	return nil
+}
+
+// 文件名允许的字符：字母、数字、空格和部分符号，不允许 " ' * < > ? ` | : \ 等
+func goFileNameRuneOK(r rune) bool {
+	if r < utf8.RuneSelf {
+		return '0' <= r && r <= '9' || 'A' <= r && r <= 'Z' || 'a' <= r && r <= 'z' ||
+			strings.ContainsRune("!#$%&()+,-.=@[]^_{}~ ", r)
+	}
+	return unicode.IsLetter(r)
+}
+
+// 检查模块路径：每段只能包含字母、数字和 -._~，不能为空或全是点
+func checkGoModulePath(modPath string) error {
+	for _, elem := range strings.Split(modPath, "/") {
+		if strings.Trim(elem, ".") == "" || !goPathElemPattern.MatchString(elem) {
+			return fmt.Errorf("无效的模块路径 %q", modPath)
+		}
+	}
+	return nil
+}
+
+// go.mod 中module指令声明的模块路径
+func goModFilePath(gomod []byte) string {
+	for _, line := range strings.Split(string(gomod), "\n") {
+		line, _, _ = strings.Cut(line, "//")
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "module" {
+			continue
+		}
+		if p, err := strconv.Unquote(fields[1]); err == nil {
+			return p
+		}
+		return fields[1]
+	}
+	return ""
+}
+
+// 模块路径的主版本后缀，如 example.com/m/v2 -> v2，没有后缀时为空
+func goPathMajor(modPath string) string {
+	if elem := path.Base(modPath); goMajorPattern.MatchString(elem) {
+		return elem
+	}
+	return ""
+}
+
+// 模块路径和版本中的大写字母写作 ! 加小写字母，如 github.com/!azure -> github.com/Azure
+func unescapeGoPath(escaped string) (string, error) {
+	var b strings.Builder
+	bang := false
+	for _, r := range escaped {
+		switch {
+		case bang:
+			if r < 'a' || r > 'z' {
+				return "", fmt.Errorf("无效的转义路径 %q", escaped)
+			}
+			b.WriteRune(r - 'a' + 'A')
+			bang = false
+		case r == '!':
+			bang = true
+		case 'A' <= r && r <= 'Z':
+			return "", fmt.Errorf("无效的转义路径 %q", escaped)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if bang {
+		return "", fmt.Errorf("无效的转义路径 %q", escaped)
+	}
+	return b.String(), nil
+}
+
+func escapeGoPath(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if 'A' <= r && r <= 'Z' {
+			b.WriteByte('!')
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// 比较两个规范的语义化版本
+func compareSemver(a, b string) int {
+	coreA, preA, _ := strings.Cut(a[1:], "-")
+	coreB, preB, _ := strings.Cut(b[1:], "-")
+	numsA, numsB := strings.Split(coreA, "."), strings.Split(coreB, ".")
+	for i := 0; i < 3; i++ {
+		if c := compareNumeric(numsA[i], numsB[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	}
+
+	// 预发布版本逐段比较：数字按大小，数字小于字母，其余按字典序
+	idsA, idsB := strings.Split(preA, "."), strings.Split(preB, ".")
+	for i := 0; i < len(idsA) && i < len(idsB); i++ {
+		x, y := idsA[i], idsB[i]
+		if x == y {
+			continue
+		}
+		xNum, yNum := isNumeric(x), isNumeric(y)
+		switch {
+		case xNum && yNum:
+			return compareNumeric(x, y)
+		case xNum:
+			return -1
+		case yNum:
+			return 1
+		case x < y:
+			return -1
+		default:
+			return 1
+		}
+	}
+	switch {
+	case len(idsA) < len(idsB):
+		return -1
+	case len(idsA) > len(idsB):
+		return 1
+	}
+	return 0
+}
+
+// 比较没有前导零的十进制数字串
+func compareNumeric(a, b string) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func isNumeric(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
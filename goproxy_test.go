@@ -0,0 +1,296 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGoBuildOnceSharesError(t *testing.T) {
+	errBuild := errors.New("build failed")
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var builds int
+	var mu sync.Mutex
+	build := func() (string, error) {
+		mu.Lock()
+		builds++
+		mu.Unlock()
+		close(started)
+		<-release
+		return "", errBuild
+	}
+
+	leader := make(chan error, 1)
+	go func() {
+		_, err := goBuildOnce(context.Background(), "mod@v1", build)
+		leader <- err
+	}()
+	<-started
+
+	waiter := make(chan error, 1)
+	go func() {
+		_, err := goBuildOnce(context.Background(), "mod@v1", func() (string, error) {
+			t.Error("waiter should not build")
+			return "", nil
+		})
+		waiter <- err
+	}()
+	// 等待方进入等待后再结束构建
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	for _, ch := range []chan error{leader, waiter} {
+		if err := <-ch; !errors.Is(err, errBuild) {
+			t.Errorf("goBuildOnce = %v, want %v", err, errBuild)
+		}
+	}
+	if builds != 1 {
+		t.Errorf("builds = %d, want 1", builds)
+	}
+}
+
+func TestGoBuildOnceWaiterCanceled(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	go goBuildOnce(context.Background(), "mod@v2", func() (string, error) {
+		close(started)
+		<-release
+		return "v2", nil
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := goBuildOnce(ctx, "mod@v2", func() (string, error) { return "v2", nil }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("goBuildOnce = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestGoBuildOnceRetriesCanceledBuild(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go goBuildOnce(context.Background(), "mod@v3", func() (string, error) {
+		close(started)
+		<-release
+		return "", context.Canceled
+	})
+	<-started
+
+	type result struct {
+		version string
+		err     error
+	}
+	waiter := make(chan result, 1)
+	go func() {
+		v, err := goBuildOnce(context.Background(), "mod@v3", func() (string, error) { return "v3", nil })
+		waiter <- result{v, err}
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if r := <-waiter; r.err != nil || r.version != "v3" {
+		t.Errorf("goBuildOnce = %q, %v; want v3 after rebuilding", r.version, r.err)
+	}
+}
+
+// 伪版本请求和解析到同一提交的分支查询只下载、构建一次
+func TestGoPseudoVersionAndQueryShareBuild(t *testing.T) {
+	const commit = "abcdef0123456789abcdef0123456789abcdef01"
+	const pseudo = "v0.0.0-20240102030405-abcdef012345"
+	commitTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	oldDir := goProxyCacheDir
+	if err := setupGoProxyCache(GoProxyConfig{CacheDir: t.TempDir()}); err != nil {
+		t.Fatal(err)
+	}
+	oldRefs := repoRefs
+	repoRefs = newRefCache()
+	t.Cleanup(func() { goProxyCacheDir, repoRefs = oldDir, oldRefs })
+
+	// codeload 的源码归档：顶层目录为 <仓库>-<提交>，注释中是完整SHA
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	fw, _ := zw.CreateHeader(&zip.FileHeader{Name: "r-" + commit + "/go.mod", Modified: commitTime, Method: zip.Deflate})
+	io.WriteString(fw, "module github.com/o/r\n")
+	zw.SetComment(commit)
+	zw.Close()
+
+	var downloads atomic.Int32
+	release := make(chan struct{})
+	stubUpstream(t, func(r *http.Request) (*http.Response, error) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/info/refs"):
+			body := pktLine("# service=git-upload-pack\n") + "0000" +
+				pktLine(commit+" HEAD\x00symref=HEAD:refs/heads/main\n") +
+				pktLine(commit+" refs/heads/main\n") + "0000"
+			return stubResponse(r, http.StatusOK, nil, body), nil
+		case r.URL.Host == "codeload.github.com":
+			downloads.Add(1)
+			<-release
+			return stubResponse(r, http.StatusOK, nil, archive.String()), nil
+		}
+		return stubResponse(r, http.StatusNotFound, nil, ""), nil
+	})
+
+	mod := &goModule{
+		Path: "github.com/o/r",
+		Repo: &SourceURL{Platform: platformGitHub, Host: "github.com", Namespace: "o", Repo: "r", Kind: SourceRepo},
+	}
+	var queried string
+	var queryErr, ensureErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		queried, queryErr = mod.query(context.Background(), "main")
+	}()
+	go func() {
+		defer wg.Done()
+		ensureErr = mod.ensure(context.Background(), pseudo, ".zip")
+	}()
+	// 两个请求都进入构建后再返回归档
+	for downloads.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if queryErr != nil || queried != pseudo {
+		t.Errorf("query(main) = %q, %v; want %q", queried, queryErr, pseudo)
+	}
+	if ensureErr != nil {
+		t.Errorf("ensure(%s) = %v", pseudo, ensureErr)
+	}
+	if n := downloads.Load(); n != 1 {
+		t.Errorf("源码归档下载了 %d 次，want 1", n)
+	}
+}
+
+func TestCompareSemver(t *testing.T) {
+	// 按语义化版本规范从小到大排列
+	ordered := []string{
+		"v0.0.1",
+		"v0.9.0",
+		"v0.10.0",
+		"v1.0.0-alpha",
+		"v1.0.0-alpha.1",
+		"v1.0.0-alpha.beta",
+		"v1.0.0-beta",
+		"v1.0.0-beta.2",
+		"v1.0.0-beta.11",
+		"v1.0.0-rc.1",
+		"v1.0.0",
+		"v1.2.0",
+		"v1.10.0",
+		"v2.0.0",
+	}
+	for i, a := range ordered {
+		for j, b := range ordered {
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if got := compareSemver(a, b); got != want {
+				t.Errorf("compareSemver(%s, %s) = %d, want %d", a, b, got, want)
+			}
+		}
+	}
+
+	shuffled := []string{"v1.10.0", "v1.0.0-rc.1", "v1.2.0", "v1.0.0", "v1.0.0-beta.11", "v1.0.0-beta.2"}
+	sort.Slice(shuffled, func(i, j int) bool { return compareSemver(shuffled[i], shuffled[j]) < 0 })
+	want := []string{"v1.0.0-beta.2", "v1.0.0-beta.11", "v1.0.0-rc.1", "v1.0.0", "v1.2.0", "v1.10.0"}
+	for i := range want {
+		if shuffled[i] != want[i] {
+			t.Fatalf("sorted = %v, want %v", shuffled, want)
+		}
+	}
+}
+
+func TestIsGoVendoredPackage(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"vendor/modules.txt", false},
+		{"vendor/github.com/pkg/errors/errors.go", true},
+		{"main.go", false},
+		{"internal/vendorx/a.go", false},
+		// 与go命令一致：非顶层vendor目录按 "/vendor/" 的长度从头偏移
+		{"a/vendor/b/c.go", true},
+		{"a/vendor/modules.txt", true},
+		{"abcdefghij/vendor/x.go", true},
+	}
+	for _, tt := range tests {
+		if got := isGoVendoredPackage(tt.name); got != tt.want {
+			t.Errorf("isGoVendoredPackage(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckGoFilePath(t *testing.T) {
+	valid := []string{
+		"go.mod",
+		"internal/x/y_test.go",
+		"docs/使用说明.md",
+		"testdata/a b/[c]~{d}.txt",
+		".github/workflows/ci.yml",
+	}
+	for _, name := range valid {
+		if err := checkGoFilePath(name); err != nil {
+			t.Errorf("checkGoFilePath(%q) = %v", name, err)
+		}
+	}
+	invalid := []string{
+		"a//b.go",
+		"../a.go",
+		"a/./b.go",
+		"a/b.",
+		"a/b:c.go",
+		`a\b.go`,
+		"a/b*.go",
+		"a/b?.go",
+		"a/'b'.go",
+		"con/a.go",
+		"dir/NUL.txt",
+		"lpt1",
+	}
+	for _, name := range invalid {
+		if err := checkGoFilePath(name); err == nil {
+			t.Errorf("checkGoFilePath(%q) should fail", name)
+		}
+	}
+}
+
+func TestGoPathEscaping(t *testing.T) {
+	tests := []struct{ path, escaped string }{
+		{"github.com/Azure/azure-sdk-for-go", "github.com/!azure/azure-sdk-for-go"},
+		{"github.com/BurntSushi/toml", "github.com/!burnt!sushi/toml"},
+		{"golang.org/x/net", "golang.org/x/net"},
+	}
+	for _, tt := range tests {
+		if got := escapeGoPath(tt.path); got != tt.escaped {
+			t.Errorf("escapeGoPath(%q) = %q, want %q", tt.path, got, tt.escaped)
+		}
+		if got, err := unescapeGoPath(tt.escaped); err != nil || got != tt.path {
+			t.Errorf("unescapeGoPath(%q) = %q, %v, want %q", tt.escaped, got, err, tt.path)
+		}
+	}
+	for _, bad := range []string{"github.com/Azure/x", "github.com/!", "github.com/!1"} {
+		if _, err := unescapeGoPath(bad); err == nil {
+			t.Errorf("unescapeGoPath(%q) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// HeaderProfile 上游请求头配置：哪些客户端请求头透传，哪些请求头被覆盖
+type HeaderProfile struct {
+	// 从客户端透传到上游的请求头
+	Passthrough []string `json:"passthrough"`
+	// 固定设置的请求头，覆盖客户端的同名请求头；值为空表示删除
+	Set map[string]string `json:"set"`
+}
+
+// 请求类型
+const (
+	kindFile = "file" // 文件下载
+	kindGit  = "git"  // git smart HTTP（clone/fetch）
+	kindAPI  = "api"  // 平台API
+)
+
+// 条件请求和断点续传相关的请求头，各类请求都需要透传
+var cacheHeaders = []string{
+	"Range", "If-Range", "If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since",
+}
+
+// 内置请求头配置，键为 "平台/请求类型"，"*" 匹配任意平台。
+// Authorization 透传给上游，用于私有仓库、gated模型和LFS对象（batch响应给出的令牌）；
+// 跨主机的重定向和预签名URL不会携带它
+var defaultHeaderProfiles = map[string]HeaderProfile{
+	"*/file": {
+		Passthrough: append([]string{"User-Agent", "Accept", "Accept-Encoding", "Accept-Language", "Authorization"}, cacheHeaders...),
+	},
+	"*/git": {
+		// GitHub根据 git/ 开头的User-Agent识别smart HTTP客户端，必须透传
+		Passthrough: []string{"User-Agent", "Accept", "Accept-Encoding", "Content-Type", "Content-Encoding", "Git-Protocol", "Pragma", "Authorization"},
+	},
+	"*/api": {
+		Passthrough: append([]string{"User-Agent", "Accept", "Accept-Encoding", "Authorization"}, cacheHeaders...),
+	},
+	"huggingface/api": {
+		// paths-info 等只读POST接口使用表单请求体
+		Passthrough: append([]string{"User-Agent", "Accept", "Accept-Encoding", "Content-Type", "Authorization"}, cacheHeaders...),
+	},
+	"github/api": {
+		Passthrough: append([]string{"User-Agent", "Accept", "Accept-Encoding", "X-GitHub-Api-Version", "Authorization"}, cacheHeaders...),
+	},
+}
+
+// 预签名URL的签名参数（S3、CloudFront、Azure、GitHub Release附件）
+var signedURLParams = []string{"X-Amz-Signature", "Signature", "sig", "jwt"}
+
+// 预签名URL已经通过查询参数认证，再带 Authorization 会被存储服务拒绝。
+// 客户端跟随改写后的 Location 访问CDN时仍会带上自己的令牌，需要在这里删除
+func removeSignedURLAuth(h http.Header, target *url.URL) {
+	query := target.Query()
+	for _, name := range signedURLParams {
+		if query.Has(name) {
+			h.Del("Authorization")
+			return
+		}
+	}
+}
+
+// 逐跳请求头（RFC 9110 7.6.1），不能在代理两侧之间转发
+var hopByHopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// 删除逐跳请求头，包括Connection中列出的请求头
+func removeHopByHopHeaders(h http.Header) {
+	for _, value := range h.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		h.Del(name)
+	}
+}
+
+// 根据主机名识别平台
+func platformOf(host string) string {
+	switch {
+	case host == "github.com" || strings.HasSuffix(host, ".github.com") || strings.HasSuffix(host, ".githubusercontent.com"):
+		return "github"
+	case host == "gitlab.com" || host == "gitlab.io" || strings.HasSuffix(host, ".gitlab.com"):
+		return "gitlab"
+	case host == "huggingface.co" || host == "hf.co" || strings.HasSuffix(host, ".huggingface.co") || strings.HasSuffix(host, ".hf.co"):
+		return "huggingface"
+	}
+	return ""
+}
+
+// 识别请求类型
+func requestKind(target *url.URL) string {
+	switch {
+	case isGitSmartHTTP(target.Path), isLFSBatchPath(target.Path):
+		return kindGit
+	case target.Host == "api.github.com", strings.HasPrefix(target.Path, "/api/"):
+		return kindAPI
+	}
+	return kindFile
+}
+
+// 查找请求头配置：配置文件中的 平台/类型 -> */类型，再回退到内置配置
+func headerProfileFor(target *url.URL) HeaderProfile {
+	kind := requestKind(target)
+	keys := []string{platformOf(target.Host) + "/" + kind, "*/" + kind}
+	for _, profiles := range []map[string]HeaderProfile{config.HeaderProfiles, defaultHeaderProfiles} {
+		for _, key := range keys {
+			if profile, ok := profiles[key]; ok {
+				return profile
+			}
+		}
+	}
+	return defaultHeaderProfiles["*/"+kindFile]
+}
+
+// 按请求头配置构造上游请求头
+func applyHeaderProfile(dst, src http.Header, profile HeaderProfile) {
+	clientHeaders := src.Clone()
+	removeHopByHopHeaders(clientHeaders)
+
+	for _, name := range profile.Passthrough {
+		for _, value := range clientHeaders.Values(name) {
+			dst.Add(name, value)
+		}
+	}
+	for name, value := range profile.Set {
+		if value == "" {
+			dst.Del(name)
+		} else {
+			dst.Set(name, value)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// 私有仓库的clone、文件下载和API请求都需要透传客户端的Authorization
+func TestHeaderProfileAuthorization(t *testing.T) {
+	for _, target := range []string{
+		"https://github.com/o/private.git/info/refs?service=git-upload-pack",
+		"https://raw.githubusercontent.com/o/private/main/a.txt",
+		"https://gitlab.com/g/p/-/raw/main/a.txt",
+		"https://huggingface.co/org/gated/resolve/main/model.safetensors",
+		"https://huggingface.co/api/models/org/gated",
+		"https://api.github.com/repos/o/private",
+	} {
+		u, _ := url.Parse(target)
+		src := http.Header{"Authorization": {"Bearer secret"}, "Cookie": {"a=b"}}
+		dst := make(http.Header)
+		applyHeaderProfile(dst, src, headerProfileFor(u))
+		if got := dst.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("%s: Authorization = %q", target, got)
+		}
+		if dst.Get("Cookie") != "" {
+			t.Errorf("%s: Cookie should not be forwarded", target)
+		}
+	}
+}
+
+func TestRemoveSignedURLAuth(t *testing.T) {
+	tests := []struct {
+		target string
+		keep   bool
+	}{
+		{"https://huggingface.co/org/m/resolve/main/a.bin", true},
+		{"https://cas-bridge.xethub.hf.co/xet/abc?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Signature=ff", false},
+		{"https://cdn-lfs.hf.co/repos/ab/cd?Expires=1&Signature=x&Key-Pair-Id=k", false},
+		{"https://release-assets.githubusercontent.com/github-production-release-asset/1/2?sp=r&sig=x&jwt=y", false},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.target)
+		h := http.Header{"Authorization": {"Bearer secret"}}
+		removeSignedURLAuth(h, u)
+		if kept := h.Get("Authorization") != ""; kept != tt.keep {
+			t.Errorf("%s: Authorization kept = %v, want %v", tt.target, kept, tt.keep)
+		}
+	}
+}
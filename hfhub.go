@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Hugging Face Hub 镜像：把 HF_ENDPOINT 设置为 <代理地址>/https://huggingface.co（或对应的虚拟主机）后，
+// huggingface_hub、transformers、hf download 的元数据API和文件下载都经过代理
+
+// Hub元数据API（仓库信息、文件列表、搜索等），只允许按仓库类型划分的只读接口
+var hubAPIPrefixes = []string{"/api/models", "/api/datasets", "/api/spaces"}
+
+// Hub主站域名，LFS/xet CDN等其他域名不在此列
+func isHubHost(host string) bool {
+	return host == "huggingface.co" || host == "hf.co"
+}
+
+// 是否是Hub的元数据API路径
+func isHubAPIPath(path string) bool {
+	for _, prefix := range hubAPIPrefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// 允许POST的只读Hub接口：paths-info 按路径查询文件信息（datasets、HfFileSystem 使用）
+func isHubReadOnlyPOST(target *url.URL) bool {
+	return isHubHost(target.Host) && isHubAPIPath(target.Path) && strings.Contains(target.Path, "/paths-info/")
+}
+
+// 是否是 HEAD /resolve/ 请求。客户端从这个响应中读取 X-Repo-Commit、X-Linked-Etag、X-Linked-Size 和 Location，
+// 服务端跟随到CDN后这些响应头会丢失，所以只跟随同一主机内的重定向（如仓库改名），跨主机的重定向返回给客户端
+func isHubResolveHEAD(req *http.Request) bool {
+	if req.Method != http.MethodHead || !isHubHost(req.URL.Host) {
+		return false
+	}
+	src, err := parseSourceURL(req.URL)
+	return err == nil && src.Kind == SourceFile && src.action() == "resolve"
+}
+
+// 删除xet相关的响应头。客户端看到 X-Xet-Hash 时会直接连接xet存储服务，绕过代理；
+// 删除后客户端按 Location 下载，经过代理访问 cas-bridge.xethub.hf.co
+func removeHubXetHeaders(target *url.URL, h http.Header) {
+	if !isHubHost(target.Host) {
+		return
+	}
+	for key := range h {
+		if strings.HasPrefix(key, "X-Xet-") {
+			h.Del(key)
+		}
+	}
+}
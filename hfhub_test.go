@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// HF_ENDPOINT 镜像：元数据API原样转发，文件链接转换为resolve
+func TestHubEndpointPaths(t *testing.T) {
+	var upstreamURL, upstreamMethod string
+	stubUpstream(t, func(r *http.Request) (*http.Response, error) {
+		upstreamURL, upstreamMethod = r.URL.String(), r.Method
+		return stubResponse(r, http.StatusOK, nil, "{}"), nil
+	})
+
+	tests := []struct {
+		method, path string
+		status       int
+		want         string
+	}{
+		{"GET", "/https://huggingface.co/api/models/org/model/revision/main", http.StatusOK, "https://huggingface.co/api/models/org/model/revision/main"},
+		{"GET", "/https://huggingface.co/api/datasets/org/ds/tree/main/data?recursive=true", http.StatusOK, "https://huggingface.co/api/datasets/org/ds/tree/main/data?recursive=true"},
+		{"POST", "/https://huggingface.co/api/datasets/org/ds/paths-info/main", http.StatusOK, "https://huggingface.co/api/datasets/org/ds/paths-info/main"},
+		{"GET", "/https://huggingface.co/org/model/resolve/main/config.json", http.StatusOK, "https://huggingface.co/org/model/resolve/main/config.json"},
+		{"GET", "/https://huggingface.co/org/model/blob/main/config.json", http.StatusOK, "https://huggingface.co/org/model/resolve/main/config.json"},
+		{"GET", "/https://huggingface.co/datasets/org/ds/resolve/v1/data/train.csv", http.StatusOK, "https://huggingface.co/datasets/org/ds/resolve/v1/data/train.csv"},
+		// 其他API和写入接口不转发
+		{"POST", "/https://huggingface.co/api/models/org/model/commit/main", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		upstreamURL, upstreamMethod = "", ""
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		w := httptest.NewRecorder()
+		proxyHandler(w, r)
+		if w.Code != tt.status || upstreamURL != tt.want {
+			t.Errorf("%s %s: status %d, 上游 %q; want %d, %q", tt.method, tt.path, w.Code, upstreamURL, tt.status, tt.want)
+		}
+		if tt.want != "" && upstreamMethod != tt.method {
+			t.Errorf("%s %s: 上游请求方法 %s", tt.method, tt.path, upstreamMethod)
+		}
+	}
+}
+
+// HEAD /resolve/ 跟随同一主机内的重定向，跨主机的重定向连同元数据响应头返回给客户端
+func TestHubResolveHEAD(t *testing.T) {
+	withConfig(t, func(cfg *Config) { cfg.Redirect.Mode = redirectFollow })
+	var requests []string
+	stubUpstream(t, func(r *http.Request) (*http.Response, error) {
+		requests = append(requests, r.Method+" "+r.URL.String())
+		if r.URL.Path == "/old/model/resolve/main/model.safetensors" {
+			// 仓库改名，同一主机内的相对重定向
+			return stubResponse(r, http.StatusTemporaryRedirect, http.Header{"Location": {"/new/model/resolve/main/model.safetensors"}}, ""), nil
+		}
+		return stubResponse(r, http.StatusFound, http.Header{
+			"Location":       {"https://cas-bridge.xethub.hf.co/xet-bridge-us/abc?X-Amz-Signature=sig"},
+			"X-Repo-Commit":  {"0123456789abcdef0123456789abcdef01234567"},
+			"Etag":           {`"abc"`},
+			"X-Linked-Etag":  {`"sha256:deadbeef"`},
+			"X-Linked-Size":  {"1048576"},
+			"X-Xet-Hash":     {"xethash"},
+			"X-Xet-Endpoint": {"https://cas-server.xethub.hf.co"},
+		}, ""), nil
+	})
+
+	r := httptest.NewRequest("HEAD", "/https://huggingface.co/old/model/resolve/main/model.safetensors", nil)
+	r.Host = "proxy.local"
+	w := httptest.NewRecorder()
+	proxyHandler(w, r)
+
+	wantRequests := []string{
+		"HEAD https://huggingface.co/old/model/resolve/main/model.safetensors",
+		"HEAD https://huggingface.co/new/model/resolve/main/model.safetensors",
+	}
+	if len(requests) != len(wantRequests) || requests[0] != wantRequests[0] || requests[1] != wantRequests[1] {
+		t.Fatalf("上游请求 = %q, want %q", requests, wantRequests)
+	}
+	if w.Code != http.StatusFound {
+		t.Fatalf("status = %d, want 302", w.Code)
+	}
+	h := w.Header()
+	if got, want := h.Get("Location"), "http://proxy.local/https://cas-bridge.xethub.hf.co/xet-bridge-us/abc?X-Amz-Signature=sig"; got != want {
+		t.Errorf("Location = %q, want %q", got, want)
+	}
+	for key, want := range map[string]string{
+		"X-Repo-Commit": "0123456789abcdef0123456789abcdef01234567",
+		"Etag":          `"abc"`,
+		"X-Linked-Etag": `"sha256:deadbeef"`,
+		"X-Linked-Size": "1048576",
+		"X-Xet-Hash":    "",
+	} {
+		if got := h.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if h.Get("X-Xet-Endpoint") != "" {
+		t.Error("X-Xet-* 响应头应被删除")
+	}
+}
+
+func TestCheckUpstreamRedirectHubHEAD(t *testing.T) {
+	withConfig(t, func(cfg *Config) { cfg.Redirect = RedirectConfig{Mode: redirectFollow, MaxHops: 5} })
+	const cdn = "https://cas-bridge.xethub.hf.co/xet-bridge-us/abc"
+	tests := []struct {
+		method, first, next string
+		follow              bool
+	}{
+		{"HEAD", "https://huggingface.co/org/model/resolve/main/a.bin", "https://huggingface.co/org/renamed/resolve/main/a.bin", true},
+		{"HEAD", "https://huggingface.co/org/model/resolve/main/a.bin", cdn, false},
+		{"HEAD", "https://hf.co/org/model/resolve/main/a.bin", "https://huggingface.co/org/model/resolve/main/a.bin", false},
+		// GET下载和非resolve请求照常跟随
+		{"GET", "https://huggingface.co/org/model/resolve/main/a.bin", cdn, true},
+		{"HEAD", "https://huggingface.co/api/models/org/model", "https://huggingface.co/api/models/org/renamed", true},
+	}
+	for _, tt := range tests {
+		via := []*http.Request{httptest.NewRequest(tt.method, tt.first, nil)}
+		next := httptest.NewRequest(tt.method, tt.next, nil)
+		err := checkUpstreamRedirect(next, via)
+		if follow := err == nil; follow != tt.follow {
+			t.Errorf("%s %s -> %s: err = %v, want follow %v", tt.method, tt.first, tt.next, err, tt.follow)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// 快照清单最多包含的文件数，防止超大数据集拖垮服务
+const maxSnapshotFiles = 100000
+
+// HFSnapshotFile 快照中的一个文件
+type HFSnapshotFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// LFS文件的sha256，普通git文件为空
+	SHA256 string `json:"sha256,omitempty"`
+	// 经过代理的 /resolve/ 下载地址
+	URL string `json:"url"`
+}
+
+// HFSnapshotResponse 快照清单接口的JSON响应
+type HFSnapshotResponse struct {
+	Success  bool   `json:"success"`
+	Repo     string `json:"repo,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Revision string `json:"revision,omitempty"`
+	// 下载地址固定到的提交SHA
+	Commit    string           `json:"commit,omitempty"`
+	FileCount int              `json:"file_count"`
+	TotalSize int64            `json:"total_size"`
+	Files     []HFSnapshotFile `json:"files,omitempty"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// Hub文件列表接口返回的条目
+type hubTreeEntry struct {
+	Type string `json:"type"`
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	LFS  *struct {
+		OID string `json:"oid"`
+	} `json:"lfs"`
+}
+
+// Hugging Face仓库快照清单接口
+// GET /api/hf/snapshot?repo=<id>&revision=<rev>&type=model|dataset|space&include=<glob>&exclude=<glob>&format=json|urls|aria2
+func hfSnapshotAPI(w http.ResponseWriter, r *http.Request) {
+	setAPIHeaders(w, "GET, OPTIONS")
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	fail := func(msg string) {
+		if format == "" || format == "json" {
+			json.NewEncoder(w).Encode(HFSnapshotResponse{Success: false, Error: msg})
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, msg, http.StatusBadRequest)
+	}
+
+	switch format {
+	case "", "json", "urls", "aria2":
+	default:
+		fail("不支持的格式（可选 json、urls、aria2）")
+		return
+	}
+	if r.Method != "GET" && r.Method != "HEAD" {
+		fail("只支持GET请求")
+		return
+	}
+	if !config.platformEnabled(platformHuggingFace) {
+		fail("未启用Hugging Face")
+		return
+	}
+
+	src, err := hubSnapshotSource(query.Get("repo"), query.Get("type"), query.Get("revision"))
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+	include := splitGlobs(query["include"])
+	exclude := splitGlobs(query["exclude"])
+
+	response, err := hfSnapshot(r.Context(), requestBaseURL(r), src, include, exclude)
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+
+	switch format {
+	case "urls":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, f := range response.Files {
+			fmt.Fprintln(w, f.URL)
+		}
+	case "aria2":
+		// aria2c -i <文件> -d <目录>
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, f := range response.Files {
+			fmt.Fprintf(w, "%s\n  out=%s\n", f.URL, f.Path)
+			if f.SHA256 != "" {
+				fmt.Fprintf(w, "  checksum=sha-256=%s\n", f.SHA256)
+			}
+		}
+	default:
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// 按查询参数构造Hub仓库
+func hubSnapshotSource(repo, repoType, revision string) (*SourceURL, error) {
+	repo = strings.Trim(strings.TrimSpace(repo), "/")
+	if repo == "" {
+		return nil, fmt.Errorf("缺少repo参数，如 repo=org/model")
+	}
+	parts := strings.Split(repo, "/")
+	if len(parts) > 2 {
+		return nil, fmt.Errorf("无效的仓库名: %s", repo)
+	}
+	for _, p := range parts {
+		if p == "" || p == "." || p == ".." {
+			return nil, fmt.Errorf("无效的仓库名: %s", repo)
+		}
+	}
+
+	switch repoType {
+	case "":
+		repoType = "model"
+	case "model", "dataset", "space":
+	default:
+		return nil, fmt.Errorf("无效的仓库类型 %q（可选 model、dataset、space）", repoType)
+	}
+	if revision == "" {
+		revision = "main"
+	}
+
+	src := &SourceURL{
+		Platform: platformHuggingFace,
+		Host:     "huggingface.co",
+		RepoType: repoType,
+		Ref:      revision,
+		Kind:     SourceRepo,
+	}
+	if len(parts) == 2 {
+		src.Namespace, src.Repo = parts[0], parts[1]
+	} else {
+		src.Repo = parts[0]
+	}
+	return src, nil
+}
+
+// 列出仓库在指定版本下的所有文件，生成固定到提交SHA的加速链接
+func hfSnapshot(ctx context.Context, baseURL string, src *SourceURL, include, exclude []string) (HFSnapshotResponse, error) {
+	commit, err := resolveCommit(ctx, src)
+	if err != nil {
+		return HFSnapshotResponse{}, fmt.Errorf("解析版本失败: %w", err)
+	}
+
+	response := HFSnapshotResponse{
+		Success:  true,
+		Repo:     src.RepoID(),
+		Type:     src.RepoType,
+		Revision: src.Ref,
+		Commit:   commit,
+		Files:    []HFSnapshotFile{},
+	}
+
+	apiURL := fmt.Sprintf("https://huggingface.co/api/%ss/%s/tree/%s?recursive=true&expand=false",
+		src.RepoType, escapePathSegments(src.RepoID()), commit)
+	seen := 0
+	for apiURL != "" {
+		var entries []hubTreeEntry
+		header, err := fetchUpstreamJSONHeader(ctx, apiURL, &entries)
+		if err != nil {
+			return HFSnapshotResponse{}, fmt.Errorf("获取文件列表失败: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.Type != "file" {
+				continue
+			}
+			if seen++; seen > maxSnapshotFiles {
+				return HFSnapshotResponse{}, fmt.Errorf("仓库文件数超过 %d，请使用include缩小范围", maxSnapshotFiles)
+			}
+			if !matchSnapshotPath(entry.Path, include, exclude) {
+				continue
+			}
+
+			file := *src
+			file.Kind = SourceFile
+			file.Ref = commit
+			file.FilePath = entry.Path
+			f := HFSnapshotFile{Path: entry.Path, Size: entry.Size, URL: baseURL + "/" + file.RawURL()}
+			if entry.LFS != nil {
+				f.SHA256 = entry.LFS.OID
+			}
+			response.Files = append(response.Files, f)
+			response.TotalSize += entry.Size
+		}
+		if apiURL, err = nextPageURL(header); err != nil {
+			return HFSnapshotResponse{}, err
+		}
+	}
+	response.FileCount = len(response.Files)
+	return response, nil
+}
+
+// 从 Link 响应头中取出下一页地址，没有下一页时返回空字符串
+func nextPageURL(header http.Header) (string, error) {
+	for _, link := range strings.Split(header.Get("Link"), ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+		if !ok || !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		next, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+		if err != nil {
+			return "", fmt.Errorf("无效的分页地址")
+		}
+		if err := checkEgressURL(next); err != nil {
+			return "", fmt.Errorf("分页地址被出站策略拒绝: %w", err)
+		}
+		return next.String(), nil
+	}
+	return "", nil
+}
+
+// 展开 include/exclude 参数，支持重复参数和逗号分隔
+func splitGlobs(values []string) []string {
+	var globs []string
+	for _, v := range values {
+		for _, g := range strings.Split(v, ",") {
+			if g = strings.TrimSpace(g); g != "" {
+				globs = append(globs, g)
+			}
+		}
+	}
+	return globs
+}
+
+// 按glob筛选文件：先匹配include（为空时包含全部），再排除exclude
+func matchSnapshotPath(filePath string, include, exclude []string) bool {
+	if len(include) > 0 && !matchAnyGlob(filePath, include) {
+		return false
+	}
+	return !matchAnyGlob(filePath, exclude)
+}
+
+// glob匹配：以 / 结尾表示目录下的所有文件；不含 / 的模式同时匹配文件名，如 *.safetensors
+func matchAnyGlob(filePath string, globs []string) bool {
+	for _, g := range globs {
+		if strings.HasSuffix(g, "/") {
+			if strings.HasPrefix(filePath, g) {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(g, filePath); ok {
+			return true
+		}
+		if !strings.Contains(g, "/") {
+			if ok, _ := path.Match(g, path.Base(filePath)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchAnyGlob(t *testing.T) {
+	tests := []struct {
+		path  string
+		globs []string
+		want  bool
+	}{
+		{"model.safetensors", []string{"*.safetensors"}, true},
+		// 不含 / 的模式同时匹配文件名
+		{"unet/diffusion_pytorch_model.safetensors", []string{"*.safetensors"}, true},
+		{"unet/config.json", []string{"*.safetensors"}, false},
+		// 以 / 结尾表示目录下的所有文件
+		{"unet/sub/config.json", []string{"unet/"}, true},
+		{"unet2/config.json", []string{"unet/"}, false},
+		{"unet/config.json", []string{"unet/*.json"}, true},
+		{"unet/sub/config.json", []string{"unet/*.json"}, false},
+		{"README.md", []string{"*.bin", "README.*"}, true},
+		{"README.md", nil, false},
+		// 无效的模式不匹配
+		{"a[b", []string{"a[b"}, false},
+	}
+	for _, tt := range tests {
+		if got := matchAnyGlob(tt.path, tt.globs); got != tt.want {
+			t.Errorf("matchAnyGlob(%q, %q) = %v, want %v", tt.path, tt.globs, got, tt.want)
+		}
+	}
+}
+
+func TestNextPageURL(t *testing.T) {
+	tests := []struct {
+		link    string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{`<https://huggingface.co/api/models/o/m/tree/main?cursor=abc>; rel="next"`, "https://huggingface.co/api/models/o/m/tree/main?cursor=abc", false},
+		{`<https://huggingface.co/api/models/o/m/tree/main?cursor=a>; rel="prev", <https://huggingface.co/api/models/o/m/tree/main?cursor=c>; rel="next"`, "https://huggingface.co/api/models/o/m/tree/main?cursor=c", false},
+		{`<https://huggingface.co/api/models/o/m/tree/main?cursor=a>; rel="prev"`, "", false},
+		// 分页地址同样要经过出站策略
+		{`<https://example.com/steal>; rel="next"`, "", true},
+		{`<http://huggingface.co/api/models/o/m/tree/main>; rel="next"`, "", true},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		if tt.link != "" {
+			h.Set("Link", tt.link)
+		}
+		got, err := nextPageURL(h)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("nextPageURL(%q) = %q, %v; want %q, err %v", tt.link, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestHFSnapshotPagination(t *testing.T) {
+	treeURL := "https://huggingface.co/api/models/org/model/tree/" + testCommit + "?recursive=true&expand=false"
+	var requested []string
+	stubUpstream(t, func(r *http.Request) (*http.Response, error) {
+		requested = append(requested, r.URL.String())
+		switch {
+		case r.URL.Path == "/api/models/org/model/revision/main":
+			return stubResponse(r, http.StatusOK, nil, `{"sha": "`+testCommit+`"}`), nil
+		case r.URL.String() == treeURL:
+			h := http.Header{"Link": {"<" + treeURL + `&cursor=p2>; rel="next"`}}
+			return stubResponse(r, http.StatusOK, h, `[
+				{"type": "directory", "path": "unet"},
+				{"type": "file", "path": "config.json", "size": 100},
+				{"type": "file", "path": "README.md", "size": 10}
+			]`), nil
+		case r.URL.String() == treeURL+"&cursor=p2":
+			return stubResponse(r, http.StatusOK, nil, `[
+				{"type": "file", "path": "unet/model.safetensors", "size": 2000, "lfs": {"oid": "abc123"}}
+			]`), nil
+		}
+		return stubResponse(r, http.StatusNotFound, nil, ""), nil
+	})
+
+	r := httptest.NewRequest("GET", "/api/hf/snapshot?repo=org/model&include=*.json,*.safetensors", nil)
+	r.Host = "proxy.local"
+	w := httptest.NewRecorder()
+	hfSnapshotAPI(w, r)
+
+	var resp HFSnapshotResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Success || resp.Commit != testCommit || resp.FileCount != 2 || resp.TotalSize != 2100 {
+		t.Fatalf("resp = %+v, 上游请求 %q", resp, requested)
+	}
+	base := "http://proxy.local/https://huggingface.co/org/model/resolve/" + testCommit + "/"
+	want := []HFSnapshotFile{
+		{Path: "config.json", Size: 100, URL: base + "config.json"},
+		{Path: "unet/model.safetensors", Size: 2000, SHA256: "abc123", URL: base + "unet/model.safetensors"},
+	}
+	for i, f := range want {
+		if resp.Files[i] != f {
+			t.Errorf("files[%d] = %+v, want %+v", i, resp.Files[i], f)
+		}
+	}
+
+	// aria2 格式带输出路径和校验和
+	r = httptest.NewRequest("GET", "/api/hf/snapshot?repo=org/model&exclude=README.md&format=aria2", nil)
+	r.Host = "proxy.local"
+	w = httptest.NewRecorder()
+	hfSnapshotAPI(w, r)
+	wantAria2 := base + "config.json\n  out=config.json\n" +
+		base + "unet/model.safetensors\n  out=unet/model.safetensors\n  checksum=sha-256=abc123\n"
+	if got := w.Body.String(); got != wantAria2 {
+		t.Errorf("aria2 =\n%s\nwant\n%s", got, wantAria2)
+	}
+}
+
+func TestHFSnapshotBadRequest(t *testing.T) {
+	// 文本格式的错误返回400
+	for _, query := range []string{
+		"repo=&format=urls",
+		"repo=a/b/c&format=urls",
+		"repo=org/..&format=urls",
+		"repo=org/model&type=user&format=aria2",
+		"repo=org/model&format=xml",
+	} {
+		w := httptest.NewRecorder()
+		hfSnapshotAPI(w, httptest.NewRequest("GET", "/api/hf/snapshot?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d", query, w.Code)
+		}
+	}
+}
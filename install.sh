@@ -100,7 +100,7 @@ install_service() {
     # 编译程序
     print_status "编译程序..."
     go mod tidy
-    go build -o $BINARY_NAME main.go
+    go build -o $BINARY_NAME .
     if [ $? -ne 0 ]; then
         print_error "编译失败"
         exit 1
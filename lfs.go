@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Git LFS batch 响应大小上限，单次batch最多几百个对象
+const maxLFSBatchResponseBytes = 16 << 20
+
+// LFS对象存储域名，batch响应中的下载地址指向这些域名
+var lfsStorageHosts = []string{
+	"github-cloud.githubusercontent.com",
+	"github-cloud.s3.amazonaws.com",
+}
+
+// 是否是LFS batch接口：<仓库>.git/info/lfs/objects/batch
+func isLFSBatchPath(path string) bool {
+	return strings.HasSuffix(path, "/info/lfs/objects/batch")
+}
+
+// 是否是LFS对象下载地址：GitHub的LFS存储域名，或GitLab的 /gitlab-lfs/objects/
+func isLFSObjectURL(u *url.URL) bool {
+	for _, host := range lfsStorageHosts {
+		if u.Host == host {
+			return true
+		}
+	}
+	return u.Host == "gitlab.com" && strings.Contains(u.Path, "/gitlab-lfs/objects/")
+}
+
+// 检查LFS batch请求：只接受JSON请求体，只允许下载操作。请求体读出后重新放回，供转发使用
+func checkLFSBatchRequest(r *http.Request) (string, int) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/vnd.git-lfs+json" && mediaType != "application/json" {
+		return "LFS batch请求的Content-Type必须是 application/vnd.git-lfs+json", http.StatusUnsupportedMediaType
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "请求体过大或读取失败", http.StatusRequestEntityTooLarge
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+
+	var batch struct {
+		Operation string `json:"operation"`
+	}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return "无效的LFS batch请求", http.StatusBadRequest
+	}
+	if batch.Operation != "download" {
+		return "只支持LFS下载，不支持上传", http.StatusForbidden
+	}
+	return "", 0
+}
+
+// 把LFS batch响应中的下载地址改写为经过代理的地址，不符合出站策略的地址保持不变
+func rewriteLFSBatchResponse(r *http.Request, resp *http.Response) {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxLFSBatchResponseBytes+1))
+	resp.Body.Close()
+	if err == nil && len(data) > maxLFSBatchResponseBytes {
+		err = fmt.Errorf("响应超过 %d 字节", maxLFSBatchResponseBytes)
+	}
+	if err == nil {
+		data, err = rewriteLFSHrefs(r, data)
+	}
+	if err != nil {
+		log.Printf("改写LFS batch响应失败: %v", err)
+	}
+	// 失败时仍返回读到的原始内容
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	resp.ContentLength = int64(len(data))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(data)))
+}
+
+// 改写 objects[].actions.download.href，其余字段原样保留
+func rewriteLFSHrefs(r *http.Request, data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var batch map[string]interface{}
+	if err := decoder.Decode(&batch); err != nil {
+		return data, err
+	}
+
+	objects, _ := batch["objects"].([]interface{})
+	changed := 0
+	for _, obj := range objects {
+		object, _ := obj.(map[string]interface{})
+		actions, _ := object["actions"].(map[string]interface{})
+		download, _ := actions["download"].(map[string]interface{})
+		href, _ := download["href"].(string)
+		if href == "" {
+			continue
+		}
+		target, err := url.Parse(href)
+		if err != nil || checkEgressURL(target) != nil {
+			continue
+		}
+		if proxied, ok := proxyURLFor(r, target); ok {
+			download["href"] = proxied
+			changed++
+		}
+	}
+	if changed == 0 {
+		return data, nil
+	}
+	return json.Marshal(batch)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const lfsContentType = "application/vnd.git-lfs+json"
+
+func TestCheckLFSBatchRequest(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		status      int
+	}{
+		{lfsContentType, `{"operation": "download", "objects": [{"oid": "abc", "size": 1}]}`, 0},
+		{lfsContentType + "; charset=utf-8", `{"operation": "download"}`, 0},
+		{"application/json", `{"operation": "download"}`, 0},
+		{lfsContentType, `{"operation": "upload", "objects": [{"oid": "abc", "size": 1}]}`, http.StatusForbidden},
+		{lfsContentType, `{"objects": []}`, http.StatusForbidden},
+		{lfsContentType, `operation=download`, http.StatusBadRequest},
+		{"text/plain", `{"operation": "download"}`, http.StatusUnsupportedMediaType},
+		{"", `{"operation": "download"}`, http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/https://github.com/o/r.git/info/lfs/objects/batch", strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			r.Header.Set("Content-Type", tt.contentType)
+		}
+		msg, status := checkLFSBatchRequest(r)
+		if status != tt.status || (msg == "") != (tt.status == 0) {
+			t.Errorf("%s %s: status %d, msg %q; want %d", tt.contentType, tt.body, status, msg, tt.status)
+			continue
+		}
+		if status == 0 {
+			// 请求体放回后仍可转发
+			body, _ := io.ReadAll(r.Body)
+			if string(body) != tt.body || r.ContentLength != int64(len(tt.body)) {
+				t.Errorf("请求体 = %q (%d), want %q", body, r.ContentLength, tt.body)
+			}
+		}
+	}
+}
+
+func TestRewriteLFSHrefs(t *testing.T) {
+	input := `{
+		"transfer": "basic",
+		"objects": [
+			{"oid": "a1", "size": 12345678901234, "authenticated": true, "actions": {"download": {
+				"href": "https://github-cloud.githubusercontent.com/alambic/media/1?sig=a%2Bb",
+				"header": {"Authorization": "RemoteAuth token", "X-Custom": "1"},
+				"expires_at": "2030-01-01T00:00:00Z"}}},
+			{"oid": "a2", "size": 1, "error": {"code": 404, "message": "Object does not exist"}},
+			{"oid": "a3", "size": 2, "actions": {"download": {"href": "https://example.com/object"}}},
+			{"oid": "a4", "size": 3, "actions": {"upload": {"href": "https://github-cloud.s3.amazonaws.com/up"}}}
+		]
+	}`
+	r := httptest.NewRequest("POST", "/https://github.com/o/r.git/info/lfs/objects/batch", nil)
+	r.Host = "proxy.local"
+	out, err := rewriteLFSHrefs(r, []byte(input))
+	if err != nil {
+		t.Fatalf("rewriteLFSHrefs: %v", err)
+	}
+
+	var got, want map[string]interface{}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatal(err)
+	}
+	json.Unmarshal([]byte(input), &want)
+	download := want["objects"].([]interface{})[0].(map[string]interface{})["actions"].(map[string]interface{})["download"].(map[string]interface{})
+	download["href"] = "http://proxy.local/https://github-cloud.githubusercontent.com/alambic/media/1?sig=a%2Bb"
+
+	gotJSON, _ := json.Marshal(got)
+	wantJSON, _ := json.Marshal(want)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("改写结果 =\n%s\nwant\n%s", gotJSON, wantJSON)
+	}
+	// 大整数不能因为解析成浮点数而丢失精度
+	if !strings.Contains(string(out), `"size":12345678901234`) {
+		t.Errorf("size 精度丢失: %s", out)
+	}
+}
+
+func TestRewriteLFSHrefsUnchanged(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", nil)
+	for _, input := range []string{
+		// 没有可改写的地址时原样返回
+		`{"objects": [{"oid": "a", "error": {"code": 404}}]}`,
+		`{"message": "Not Found"}`,
+	} {
+		out, err := rewriteLFSHrefs(r, []byte(input))
+		if err != nil || string(out) != input {
+			t.Errorf("rewriteLFSHrefs(%s) = %s, %v", input, out, err)
+		}
+	}
+	if out, err := rewriteLFSHrefs(r, []byte("<html>")); err == nil || string(out) != "<html>" {
+		t.Errorf("非JSON响应: %s, %v", out, err)
+	}
+}
+
+func TestProxyLFSBatch(t *testing.T) {
+	const upstreamBody = `{"objects": [{"oid": "a", "size": 1, "actions": {"download": {"href": "https://github-cloud.githubusercontent.com/alambic/media/1"}}}]}`
+	var upstreamCalls int
+	stubUpstream(t, func(r *http.Request) (*http.Response, error) {
+		upstreamCalls++
+		h := http.Header{"Content-Type": {lfsContentType}, "Content-Length": {strconv.Itoa(len(upstreamBody))}}
+		return stubResponse(r, http.StatusOK, h, upstreamBody), nil
+	})
+
+	post := func(contentType, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/https://github.com/o/r.git/info/lfs/objects/batch", strings.NewReader(body))
+		r.Host = "proxy.local"
+		r.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		proxyHandler(w, r)
+		return w
+	}
+
+	w := post(lfsContentType, `{"operation": "download", "objects": [{"oid": "a", "size": 1}]}`)
+	want := `"href":"http://proxy.local/https://github-cloud.githubusercontent.com/alambic/media/1"`
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), want) {
+		t.Fatalf("download: status %d, body %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Length") != strconv.Itoa(w.Body.Len()) {
+		t.Errorf("Content-Length = %s, body %d 字节", w.Header().Get("Content-Length"), w.Body.Len())
+	}
+
+	upstreamCalls = 0
+	if w := post(lfsContentType, `{"operation": "upload"}`); w.Code != http.StatusForbidden {
+		t.Errorf("upload: status %d", w.Code)
+	}
+	if w := post("text/plain", `{"operation": "download"}`); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("text/plain: status %d", w.Code)
+	}
+	if upstreamCalls != 0 {
+		t.Errorf("被拒绝的请求不应转发到上游，实际 %d 次", upstreamCalls)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// 上游API返回404
+var errUpstreamNotFound = errors.New("上游资源不存在")
+
+// PinResponse 固定版本接口的响应
+type PinResponse struct {
+	Success bool `json:"success"`
+	// 固定到提交SHA的加速链接
+	PinnedURL string `json:"pinned_url"`
+	// 固定到提交SHA的原始链接
+	PinnedSourceURL string     `json:"pinned_source_url"`
+	Ref             string     `json:"ref"`
+	Commit          string     `json:"commit"`
+	Source          *SourceURL `json:"source,omitempty"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// 固定版本接口：把分支或标签链接解析为提交SHA链接
+// GET /api/pin?url=<原始链接> 或 POST {"original_url": "..."}
+func pinAPI(w http.ResponseWriter, r *http.Request) {
+	setAPIHeaders(w, "GET, POST, OPTIONS")
+
+	var originalURL string
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+		return
+	case "GET":
+		originalURL = r.URL.Query().Get("url")
+	case "POST":
+		var req GenerateLinksRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(PinResponse{Success: false, Error: "请求格式错误"})
+			return
+		}
+		originalURL = req.OriginalURL
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(PinResponse{Success: false, Error: "只支持GET或POST请求"})
+		return
+	}
+
+	response, status := pinLink(r.Context(), requestBaseURL(r), originalURL)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// 解析链接中的分支或标签并生成固定到提交SHA的链接，同时返回HTTP状态码
+func pinLink(ctx context.Context, baseURL, originalURL string) (PinResponse, int) {
+	fail := func(status int, msg string) (PinResponse, int) {
+		return PinResponse{Success: false, Error: msg}, status
+	}
+
+	src, err := ParseSourceURL(originalURL)
+	if err != nil {
+		return fail(http.StatusBadRequest, "无法识别的链接，仅支持GitHub、GitLab、Hugging Face的文件或目录链接")
+	}
+	if src.Kind != SourceFile && src.Kind != SourceTree {
+		return fail(http.StatusBadRequest, "仅支持文件或目录链接")
+	}
+
+	// 固定版本需要准确的ref，总是查询上游ref列表；查询失败时不能按猜测的ref固定
+	if err := resolveSourceRef(ctx, src); err != nil {
+		return fail(pinErrorStatus(err), "解析ref失败: "+err.Error())
+	}
+
+	commit, err := resolveCommit(ctx, src)
+	if err != nil {
+		return fail(pinErrorStatus(err), "解析提交失败: "+err.Error())
+	}
+
+	pinned := *src
+	pinned.Ref = commit
+	pinnedSource := pinned.RefURL()
+	return PinResponse{
+		Success:         true,
+		PinnedURL:       baseURL + "/" + pinnedSource,
+		PinnedSourceURL: pinnedSource,
+		Ref:             src.Ref,
+		Commit:          commit,
+		Source:          src,
+	}, http.StatusOK
+}
+
+// 解析失败的状态码：仓库、分支或标签不存在时为404，其他上游错误为502
+func pinErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errUpstreamNotFound), errors.Is(err, errRepoNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errUnknownSource):
+		return http.StatusBadRequest
+	}
+	return http.StatusBadGateway
+}
+
+// RefURL 按当前ref生成文件或目录链接（文件为直接下载地址）
+func (s *SourceURL) RefURL() string {
+	if s.Kind == SourceFile {
+		return s.RawURL()
+	}
+	tree := s.RepoURL() + "/tree/" + escapePathSegments(s.Ref)
+	if s.Platform == platformGitLab {
+		tree = s.RepoURL() + "/-/tree/" + escapePathSegments(s.Ref)
+	}
+	if s.FilePath != "" {
+		tree += "/" + escapePathSegments(s.FilePath)
+	}
+	return tree
+}
+
+// 通过上游API把分支或标签解析为提交SHA
+func resolveCommit(ctx context.Context, src *SourceURL) (string, error) {
+	if isCommitSHA(src.Ref) {
+		return src.Ref, nil
+	}
+
+	var apiURL string
+	switch src.Platform {
+	case platformGitHub:
+		apiURL = fmt.Sprintf("https://api.github.com/repos/%s/%s/commits/%s",
+			url.PathEscape(src.Namespace), url.PathEscape(src.Repo), escapePathSegments(src.Ref))
+	case platformGitLab:
+		apiURL = fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/repository/commits/%s",
+			url.PathEscape(src.RepoID()), url.PathEscape(src.Ref))
+	case platformHuggingFace:
+		apiURL = fmt.Sprintf("https://huggingface.co/api/%ss/%s/revision/%s",
+			src.RepoType, escapePathSegments(src.RepoID()), url.PathEscape(src.Ref))
+	default:
+		return "", errUnknownSource
+	}
+
+	var result struct {
+		SHA string `json:"sha"` // GitHub、Hugging Face
+		ID  string `json:"id"`  // GitLab
+	}
+	if err := fetchUpstreamJSON(ctx, apiURL, &result); err != nil {
+		return "", err
+	}
+	commit := result.SHA
+	if commit == "" {
+		commit = result.ID
+	}
+	if !isCommitSHA(commit) {
+		return "", fmt.Errorf("上游未返回有效的提交SHA")
+	}
+	return commit, nil
+}
+
+// 请求上游API并解析JSON响应
+func fetchUpstreamJSON(ctx context.Context, apiURL string, v interface{}) error {
+	_, err := fetchUpstreamJSONHeader(ctx, apiURL, v)
+	return err
+}
+
+// 请求上游API并解析JSON响应，同时返回响应头（如分页用的Link）
+func fetchUpstreamJSONHeader(ctx context.Context, apiURL string, v interface{}) (http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "ghproxy/"+Version)
+	req.Header.Set("Accept", "application/json")
+	token := upstreamTokens.apply(req.Header, req.URL)
+
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	upstreamTokens.record(token, resp)
+	if req.URL.Host == "api.github.com" {
+		recordGitHubRateLimit(resp.Header)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w（上游返回 %s）", errUpstreamNotFound, resp.Status)
+		}
+		return nil, fmt.Errorf("上游返回 %s", resp.Status)
+	}
+	return resp.Header, json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testCommit = "0123456789abcdef0123456789abcdef01234567"
+
+// 模拟上游：info/refs 返回ref列表，提交API只认识列表中的ref
+func stubPinUpstream(t *testing.T) {
+	t.Helper()
+	old := repoRefs
+	repoRefs = newRefCache()
+	t.Cleanup(func() { repoRefs = old })
+
+	refs := []string{"refs/heads/main", "refs/heads/feature/login", "refs/tags/v1.0"}
+	stubUpstream(t, func(r *http.Request) (*http.Response, error) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/info/refs"):
+			if strings.Contains(r.URL.Path, "/missing") {
+				return stubResponse(r, http.StatusNotFound, nil, ""), nil
+			}
+			body := pktLine("# service=git-upload-pack\n") + "0000"
+			for _, ref := range refs {
+				body += pktLine(testCommit + " " + ref + "\n")
+			}
+			return stubResponse(r, http.StatusOK, nil, body+"0000"), nil
+		case r.URL.Host == "api.github.com", r.URL.Host == "gitlab.com", r.URL.Host == "huggingface.co":
+			// GitHub：/repos/o/r/commits/<ref>；GitLab：/projects/<id>/repository/commits/<ref>
+			ref, _ := url.PathUnescape(r.URL.EscapedPath()[strings.LastIndex(r.URL.EscapedPath(), "/commits/")+len("/commits/"):])
+			if strings.Contains(r.URL.Path, "/revision/") {
+				ref = r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+			}
+			for _, known := range []string{"main", "feature/login", "v1.0"} {
+				if ref == known {
+					body := `{"sha":"` + testCommit + `","id":"` + testCommit + `"}`
+					return stubResponse(r, http.StatusOK, http.Header{"Content-Type": {"application/json"}}, body), nil
+				}
+			}
+			return stubResponse(r, http.StatusNotFound, nil, `{"message":"Not Found"}`), nil
+		}
+		t.Errorf("unexpected upstream request %s", r.URL)
+		return stubResponse(r, http.StatusBadGateway, nil, ""), nil
+	})
+}
+
+func TestPinAPI(t *testing.T) {
+	stubPinUpstream(t)
+	tests := []struct {
+		original   string
+		status     int
+		pinned     string
+		ref        string
+		errContain string
+	}{
+		{
+			original: "https://raw.githubusercontent.com/o/r/main/install.sh",
+			status:   http.StatusOK,
+			pinned:   "https://raw.githubusercontent.com/o/r/" + testCommit + "/install.sh",
+			ref:      "main",
+		},
+		{
+			// 已经固定的链接原样返回
+			original: "https://github.com/o/r/blob/" + testCommit + "/install.sh",
+			status:   http.StatusOK,
+			pinned:   "https://raw.githubusercontent.com/o/r/" + testCommit + "/install.sh",
+			ref:      testCommit,
+		},
+		{
+			// 带斜杠的分支名
+			original: "https://github.com/o/r/blob/feature/login/src/app.go",
+			status:   http.StatusOK,
+			pinned:   "https://raw.githubusercontent.com/o/r/" + testCommit + "/src/app.go",
+			ref:      "feature/login",
+		},
+		{
+			original: "https://gitlab.com/group/sub/project/-/tree/v1.0/docs",
+			status:   http.StatusOK,
+			pinned:   "https://gitlab.com/group/sub/project/-/tree/" + testCommit + "/docs",
+			ref:      "v1.0",
+		},
+		{
+			original: "https://huggingface.co/org/model/resolve/main/config.json",
+			status:   http.StatusOK,
+			pinned:   "https://huggingface.co/org/model/resolve/" + testCommit + "/config.json",
+			ref:      "main",
+		},
+		{original: "https://github.com/o/r/blob/nope/install.sh", status: http.StatusNotFound, errContain: "解析提交失败"},
+		{original: "https://github.com/o/missing/blob/main/install.sh", status: http.StatusNotFound, errContain: "解析ref失败"},
+		{original: "https://github.com/o/r/releases/download/v1.0/tool.tar.gz", status: http.StatusBadRequest},
+		{original: "https://gist.githubusercontent.com/u/abc/raw/a.sh", status: http.StatusBadRequest},
+		{original: "https://example.com/o/r/blob/main/a.sh", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/pin?url="+url.QueryEscape(tt.original), nil)
+		r.Host = "proxy.local"
+		w := httptest.NewRecorder()
+		pinAPI(w, r)
+
+		var resp PinResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: invalid JSON %q", tt.original, w.Body.String())
+		}
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d (%s)", tt.original, w.Code, tt.status, resp.Error)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			if resp.Success || !strings.Contains(resp.Error, tt.errContain) {
+				t.Errorf("%s: response %+v, want error containing %q", tt.original, resp, tt.errContain)
+			}
+			continue
+		}
+		if resp.PinnedSourceURL != tt.pinned || resp.PinnedURL != "http://proxy.local/"+tt.pinned || resp.Ref != tt.ref || resp.Commit != testCommit {
+			t.Errorf("%s: response %+v, want pinned %s, ref %s", tt.original, resp, tt.pinned, tt.ref)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 默认请求体大小上限：git-upload-pack 的协商请求在大仓库上也很少超过这个大小
+const defaultMaxBodyBytes = 10 << 20
+
+// 允许POST的只读API接口（主机 -> 路径）
+var readOnlyPOSTEndpoints = map[string][]string{
+	// Markdown渲染接口只返回渲染结果，不修改任何数据
+	"api.github.com": {"/markdown", "/markdown/raw"},
+}
+
+// 是否是git smart HTTP协议的拉取路径（info/refs 或 git-upload-pack）
+func isGitSmartHTTP(path string) bool {
+	return strings.HasSuffix(path, "/info/refs") || strings.HasSuffix(path, "/git-upload-pack")
+}
+
+// 检查请求方法是否允许转发到目标URL。允许时返回true；否则返回false及允许的方法列表，
+// 推送（info/refs?service=git-receive-pack）没有可用的方法，返回空列表
+func checkMethodPolicy(method string, target *url.URL) (bool, string) {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		// 禁止推送；不带service参数的是dumb协议的拉取
+		if strings.HasSuffix(target.Path, "/info/refs") {
+			if service := target.Query().Get("service"); service != "" && service != "git-upload-pack" {
+				return false, ""
+			}
+		}
+		return true, ""
+	case http.MethodPost:
+		if strings.HasSuffix(target.Path, "/git-upload-pack") {
+			return true, ""
+		}
+		for _, path := range readOnlyPOSTEndpoints[target.Host] {
+			if target.Path == path {
+				return true, ""
+			}
+		}
+		// LFS batch 在转发前检查只允许下载操作
+		if isLFSBatchPath(target.Path) || isHubReadOnlyPOST(target) {
+			return true, ""
+		}
+	}
+
+	if strings.HasSuffix(target.Path, "/git-upload-pack") {
+		return false, "POST"
+	}
+	return false, "GET, HEAD"
+}
+
+// 限制请求体：GET/HEAD不转发请求体，其他方法按配置限制大小。超过限制时返回false
+func limitRequestBody(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		r.Body = http.NoBody
+		r.ContentLength = 0
+		return true
+	}
+
+	maxBytes := config.Request.MaxBodyBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBodyBytes
+	}
+	if r.ContentLength > maxBytes {
+		return false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	return true
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCheckMethodPolicy(t *testing.T) {
+	tests := []struct {
+		method    string
+		target    string
+		wantOK    bool
+		wantAllow string
+	}{
+		{"GET", "https://github.com/o/r/blob/main/a.txt", true, ""},
+		{"HEAD", "https://github.com/o/r/blob/main/a.txt", true, ""},
+		{"GET", "https://github.com/o/r.git/info/refs?service=git-upload-pack", true, ""},
+		{"GET", "https://github.com/o/r.git/info/refs", true, ""}, // dumb协议
+		{"GET", "https://github.com/o/r.git/info/refs?service=git-receive-pack", false, ""},
+		{"POST", "https://github.com/o/r.git/git-upload-pack", true, ""},
+		{"POST", "https://github.com/o/r.git/git-receive-pack", false, "GET, HEAD"},
+		{"PUT", "https://github.com/o/r.git/git-upload-pack", false, "POST"},
+		{"POST", "https://api.github.com/markdown", true, ""},
+		{"POST", "https://api.github.com/repos/o/r/issues", false, "GET, HEAD"},
+		{"POST", "https://github.com/o/r.git/info/lfs/objects/batch", true, ""},
+		{"DELETE", "https://raw.githubusercontent.com/o/r/main/a.txt", false, "GET, HEAD"},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.target)
+		ok, allow := checkMethodPolicy(tt.method, u)
+		if ok != tt.wantOK || allow != tt.wantAllow {
+			t.Errorf("checkMethodPolicy(%s %s) = %v, %q; want %v, %q", tt.method, tt.target, ok, allow, tt.wantOK, tt.wantAllow)
+		}
+	}
+}
+
+// 分块传输的请求体在转发过程中超过限制时返回413
+func TestServeProxyChunkedBodyTooLarge(t *testing.T) {
+	oldClient, oldMax := upstreamClient, config.Request.MaxBodyBytes
+	defer func() { upstreamClient, config.Request.MaxBodyBytes = oldClient, oldMax }()
+	config.Request.MaxBodyBytes = 16
+	upstreamClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Header: make(http.Header), Request: r}, nil
+	})}
+
+	r := httptest.NewRequest("POST", "/https://github.com/o/r.git/git-upload-pack", io.NopCloser(strings.NewReader(strings.Repeat("x", 64))))
+	r.ContentLength = -1
+	w := httptest.NewRecorder()
+	proxyHandler(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413: %s", w.Code, w.Body.String())
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+// 重定向模式
+const (
+	redirectFollow      = "follow"      // 服务端跟随重定向（默认）
+	redirectPassthrough = "passthrough" // 把3xx原样返回给客户端，Location改写为经过代理
+)
+
+// 代理服务自身的基础URL，与生成链接时使用的规则一致
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, r.Host)
+}
+
+// 把上游响应中指向支持域名的Location改写为经过代理的地址，其他域名保持不变
+func rewriteLocation(r *http.Request, resp *http.Response) {
+	location, err := resp.Location()
+	if err != nil {
+		return
+	}
+	if err := checkEgressURL(location); err != nil {
+		return
+	}
+
+	proxied, ok := proxyURLFor(r, location)
+	if !ok {
+		return
+	}
+	resp.Header.Set("Location", proxied)
+	log.Printf("改写重定向: %s -> %s", location.String(), proxied)
+}
+
+// 经过代理访问目标的地址：路径前缀模式为 <代理地址>/<原始URL>；
+// 虚拟主机模式为上游对应的虚拟主机，没有对应的虚拟主机时返回false。调用方需先检查出站策略
+func proxyURLFor(r *http.Request, target *url.URL) (string, bool) {
+	if _, ok := vhostUpstream(r.Host); ok {
+		return vhostURL(r, target)
+	}
+	return requestBaseURL(r) + "/" + target.String(), true
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 把上游替换为测试用的处理函数，测试结束后恢复
+func stubUpstream(t *testing.T, fn roundTripFunc) {
+	t.Helper()
+	old := upstreamClient
+	upstreamClient = &http.Client{Transport: fn, CheckRedirect: checkUpstreamRedirect}
+	t.Cleanup(func() { upstreamClient = old })
+}
+
+// 测试中修改全局配置，测试结束后恢复
+func withConfig(t *testing.T, modify func(cfg *Config)) {
+	t.Helper()
+	saved := *config
+	modify(config)
+	t.Cleanup(func() { *config = saved })
+}
+
+// 测试用的上游响应
+func stubResponse(r *http.Request, status int, header http.Header, body string) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		StatusCode:    status,
+		Status:        http.StatusText(status),
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}
+}
+
+func TestRewriteLocation(t *testing.T) {
+	tests := []struct {
+		location string
+		want     string
+	}{
+		{"https://objects.githubusercontent.com/asset?sig=a%2Bb", "http://proxy.local/https://objects.githubusercontent.com/asset?sig=a%2Bb"},
+		{"https://codeload.github.com/o/r/zip/refs/heads/main", "http://proxy.local/https://codeload.github.com/o/r/zip/refs/heads/main"},
+		// 相对地址按上游请求的地址解析
+		{"/o/r/raw/refs/heads/main/a.txt", "http://proxy.local/https://github.com/o/r/raw/refs/heads/main/a.txt"},
+		// 不支持的域名和不允许的协议保持不变
+		{"https://example.com/file", "https://example.com/file"},
+		{"http://github.com/o/r", "http://github.com/o/r"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "http://proxy.local/https://github.com/o/r/raw/main/a.txt", nil)
+		upstreamReq := httptest.NewRequest("GET", "https://github.com/o/r/raw/main/a.txt", nil)
+		resp := stubResponse(upstreamReq, http.StatusFound, http.Header{"Location": {tt.location}}, "")
+		rewriteLocation(r, resp)
+		if got := resp.Header.Get("Location"); got != tt.want {
+			t.Errorf("rewriteLocation(%q) = %q, want %q", tt.location, got, tt.want)
+		}
+	}
+}
+
+func TestCheckUpstreamRedirect(t *testing.T) {
+	withConfig(t, func(cfg *Config) { cfg.Redirect = RedirectConfig{Mode: redirectFollow, MaxHops: 2} })
+
+	newVia := func(method, target string, hops int) []*http.Request {
+		via := make([]*http.Request, hops)
+		for i := range via {
+			via[i] = httptest.NewRequest(method, target, nil)
+		}
+		return via
+	}
+	next := func(target string) *http.Request {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		return req
+	}
+
+	// 同一主机内跟随，保留Authorization
+	req := next("https://github.com/o/r/raw/refs/heads/main/a.txt")
+	if err := checkUpstreamRedirect(req, newVia("GET", "https://github.com/o/r/raw/main/a.txt", 1)); err != nil {
+		t.Errorf("same-host redirect: %v", err)
+	}
+	if req.Header.Get("Authorization") == "" {
+		t.Error("Authorization dropped on same-host redirect")
+	}
+
+	// 跨主机时去掉Authorization
+	req = next("https://raw.githubusercontent.com/o/r/main/a.txt")
+	if err := checkUpstreamRedirect(req, newVia("GET", "https://github.com/o/r/raw/main/a.txt", 1)); err != nil {
+		t.Errorf("cross-host redirect: %v", err)
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Error("Authorization kept on cross-host redirect")
+	}
+
+	// 达到跳数上限、非GET请求：返回给客户端
+	if err := checkUpstreamRedirect(next("https://github.com/x"), newVia("GET", "https://github.com/o/r", 2)); err != http.ErrUseLastResponse {
+		t.Errorf("max hops: %v, want ErrUseLastResponse", err)
+	}
+	if err := checkUpstreamRedirect(next("https://github.com/x"), newVia("POST", "https://github.com/o/r.git/git-upload-pack", 1)); err != http.ErrUseLastResponse {
+		t.Errorf("POST: %v, want ErrUseLastResponse", err)
+	}
+
+	// 不支持的域名：拒绝
+	if err := checkUpstreamRedirect(next("https://example.com/x"), newVia("GET", "https://github.com/o/r", 1)); !errors.Is(err, errEgressDenied) {
+		t.Errorf("unsupported host: %v, want errEgressDenied", err)
+	}
+
+	// passthrough模式：从不跟随
+	config.Redirect.Mode = redirectPassthrough
+	if err := checkUpstreamRedirect(next("https://github.com/x"), newVia("GET", "https://github.com/o/r", 1)); err != http.ErrUseLastResponse {
+		t.Errorf("passthrough: %v, want ErrUseLastResponse", err)
+	}
+}
+
+func TestProxyRedirectModes(t *testing.T) {
+	stubUpstream(t, func(r *http.Request) (*http.Response, error) {
+		if r.URL.Host == "github.com" {
+			return stubResponse(r, http.StatusFound, http.Header{"Location": {"https://objects.githubusercontent.com/asset"}}, ""), nil
+		}
+		return stubResponse(r, http.StatusOK, nil, "asset"), nil
+	})
+
+	for _, tt := range []struct {
+		mode     string
+		status   int
+		location string
+		body     string
+	}{
+		{redirectFollow, http.StatusOK, "", "asset"},
+		{redirectPassthrough, http.StatusFound, "http://proxy.local/https://objects.githubusercontent.com/asset", ""},
+	} {
+		withConfig(t, func(cfg *Config) { cfg.Redirect.Mode = tt.mode })
+		r := httptest.NewRequest("GET", "/https://github.com/o/r/releases/download/v1/asset", nil)
+		r.Host = "proxy.local"
+		w := httptest.NewRecorder()
+		proxyHandler(w, r)
+		if w.Code != tt.status || w.Header().Get("Location") != tt.location || w.Body.String() != tt.body {
+			t.Errorf("%s: status %d, Location %q, body %q; want %d, %q, %q",
+				tt.mode, w.Code, w.Header().Get("Location"), w.Body.String(), tt.status, tt.location, tt.body)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// info/refs 响应大小上限，标签很多的仓库也远小于这个值
+const maxRefsResponseBytes = 32 << 20
+
+// 仓库不存在或无权访问（GitHub对不存在的仓库返回401）
+var errRepoNotFound = errors.New("仓库不存在或无权访问")
+
+// ref列表缓存的仓库数上限，超过时淘汰最早过期的仓库
+const maxRefCacheEntries = 4096
+
+// 仓库ref列表缓存
+type refCacheEntry struct {
+	refs    map[string]string
+	expires time.Time
+}
+
+// 正在进行的查询，done关闭后refs和err为查询结果
+type refFetch struct {
+	done chan struct{}
+	refs map[string]string
+	err  error
+}
+
+type refCache struct {
+	mu       sync.Mutex
+	entries  map[string]refCacheEntry
+	inflight map[string]*refFetch
+}
+
+var repoRefs = newRefCache()
+
+func newRefCache() *refCache {
+	return &refCache{entries: make(map[string]refCacheEntry), inflight: make(map[string]*refFetch)}
+}
+
+// 获取仓库的ref列表（HEAD、refs/heads/*、refs/tags/*）及其指向的提交SHA，带短期缓存。
+// 同一仓库同时只查询一次，其他请求等待查询结果
+func (c *refCache) list(ctx context.Context, cloneURL string) (map[string]string, error) {
+	for {
+		now := time.Now()
+		c.mu.Lock()
+		if entry, ok := c.entries[cloneURL]; ok && now.Before(entry.expires) {
+			c.mu.Unlock()
+			return entry.refs, nil
+		}
+		f, ok := c.inflight[cloneURL]
+		if !ok {
+			f = &refFetch{done: make(chan struct{})}
+			c.inflight[cloneURL] = f
+			c.mu.Unlock()
+
+			f.refs, f.err = fetchRefs(ctx, cloneURL)
+			c.mu.Lock()
+			delete(c.inflight, cloneURL)
+			if f.err == nil {
+				c.store(cloneURL, f.refs, time.Now())
+			}
+			c.mu.Unlock()
+			close(f.done)
+			return f.refs, f.err
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		// 进行中的查询因发起请求被取消而失败时，重新查询
+		if !errors.Is(f.err, context.Canceled) && !errors.Is(f.err, context.DeadlineExceeded) {
+			return f.refs, f.err
+		}
+	}
+}
+
+// 写入缓存：清理过期的仓库，仍超过上限时淘汰最早过期的仓库。调用方持有 c.mu
+func (c *refCache) store(cloneURL string, refs map[string]string, now time.Time) {
+	for key, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, key)
+		}
+	}
+	for len(c.entries) >= maxRefCacheEntries {
+		oldest := ""
+		for key, e := range c.entries {
+			if oldest == "" || e.expires.Before(c.entries[oldest].expires) {
+				oldest = key
+			}
+		}
+		delete(c.entries, oldest)
+	}
+	c.entries[cloneURL] = refCacheEntry{refs: refs, expires: now.Add(time.Duration(config.RefResolver.CacheTTL) * time.Second)}
+}
+
+// 通过git smart HTTP的 info/refs 获取ref列表，GitHub、GitLab、Hugging Face通用
+func fetchRefs(ctx context.Context, cloneURL string) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cloneURL+"/info/refs?service=git-upload-pack", nil)
+	if err != nil {
+		return nil, err
+	}
+	// GitHub根据 git/ 开头的User-Agent识别smart HTTP客户端
+	req.Header.Set("User-Agent", "git/2.0 (ghproxy "+Version+")")
+
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", errRepoNotFound, resp.Status)
+	default:
+		return nil, fmt.Errorf("获取ref列表失败: %s", resp.Status)
+	}
+	return parseRefAdvertisement(io.LimitReader(resp.Body, maxRefsResponseBytes))
+}
+
+// 解析pkt-line格式的ref通告：<sha> <refname>[\0<capabilities>]，附注标签取 ^{} 行给出的提交
+func parseRefAdvertisement(r io.Reader) (map[string]string, error) {
+	refs := make(map[string]string)
+	reader := bufio.NewReader(r)
+	for {
+		var lenHex [4]byte
+		if _, err := io.ReadFull(reader, lenHex[:]); err != nil {
+			if err == io.EOF {
+				return refs, nil
+			}
+			return nil, err
+		}
+		n, err := strconv.ParseUint(string(lenHex[:]), 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("无效的pkt-line: %q", lenHex)
+		}
+		if n < 4 {
+			// flush-pkt 等特殊包
+			continue
+		}
+		payload := make([]byte, n-4)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return nil, err
+		}
+
+		line := strings.TrimSuffix(string(payload), "\n")
+		if i := strings.IndexByte(line, 0); i >= 0 {
+			line = line[:i]
+		}
+		sha, name, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		if tag, ok := strings.CutSuffix(name, "^{}"); ok {
+			if _, seen := refs[tag]; seen {
+				refs[tag] = sha
+			}
+			continue
+		}
+		if name == "HEAD" || strings.HasPrefix(name, "refs/heads/") || strings.HasPrefix(name, "refs/tags/") {
+			refs[name] = sha
+		}
+	}
+}
+
+// 是否是完整的提交SHA
+func isCommitSHA(ref string) bool {
+	if len(ref) != 40 && len(ref) != 64 {
+		return false
+	}
+	for _, c := range ref {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// 查询上游的ref列表，拆分带斜杠的ref和文件路径（如 blob/feature/login/src/app.go）
+func resolveSourceRef(ctx context.Context, src *SourceURL) error {
+	if src.Kind != SourceFile && src.Kind != SourceTree {
+		return nil
+	}
+	if src.Ref == "" || isCommitSHA(src.Ref) {
+		src.RefResolved = src.Ref != ""
+		return nil
+	}
+	cloneURL := src.CloneURL()
+	if cloneURL == "" {
+		return nil
+	}
+
+	refs, err := repoRefs.list(ctx, cloneURL)
+	if err != nil {
+		log.Printf("解析ref失败 %s: %v", cloneURL, err)
+		return err
+	}
+
+	joined := src.Ref
+	if src.FilePath != "" {
+		joined += "/" + src.FilePath
+	}
+	parts := strings.Split(joined, "/")
+	// 文件链接至少要留一段作为文件名
+	maxParts := len(parts)
+	if src.Kind == SourceFile {
+		maxParts--
+	}
+	// 优先匹配最长的ref
+	for n := maxParts; n >= 1; n-- {
+		candidate := strings.Join(parts[:n], "/")
+		if refs[candidate] != "" || refs["refs/heads/"+candidate] != "" || refs["refs/tags/"+candidate] != "" {
+			src.Ref = candidate
+			src.FilePath = strings.Join(parts[n:], "/")
+			src.RefResolved = true
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 编码为pkt-line
+func pktLine(s string) string {
+	return fmt.Sprintf("%04x%s", len(s)+4, s)
+}
+
+func TestParseRefAdvertisement(t *testing.T) {
+	const (
+		head   = "1111111111111111111111111111111111111111"
+		branch = "2222222222222222222222222222222222222222"
+		tag    = "3333333333333333333333333333333333333333"
+		commit = "4444444444444444444444444444444444444444"
+	)
+	body := pktLine("# service=git-upload-pack\n") + "0000" +
+		pktLine(head+" HEAD\x00multi_ack side-band-64k symref=HEAD:refs/heads/main\n") +
+		pktLine(head+" refs/heads/main\n") +
+		pktLine(branch+" refs/heads/feature/login\n") +
+		pktLine(tag+" refs/tags/v1.0\n") +
+		pktLine(commit+" refs/tags/v1.0^{}\n") +
+		pktLine(branch+" refs/pull/1/head\n") +
+		"0000"
+
+	refs, err := parseRefAdvertisement(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("parseRefAdvertisement: %v", err)
+	}
+	want := map[string]string{
+		"HEAD":                     head,
+		"refs/heads/main":          head,
+		"refs/heads/feature/login": branch,
+		// 附注标签取 ^{} 给出的提交
+		"refs/tags/v1.0": commit,
+	}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("parseRefAdvertisement = %v, want %v", refs, want)
+	}
+}
+
+func TestParseRefAdvertisementInvalid(t *testing.T) {
+	for _, body := range []string{
+		"zzzz",
+		pktLine("# service=git-upload-pack\n")[:10],
+	} {
+		if _, err := parseRefAdvertisement(strings.NewReader(body)); err == nil {
+			t.Errorf("parseRefAdvertisement(%q) should fail", body)
+		}
+	}
+}
+
+func TestResolveSourceRef(t *testing.T) {
+	const sha = "2222222222222222222222222222222222222222"
+	cloneURL := "https://github.com/owner/resolve-test.git"
+	repoRefs.mu.Lock()
+	repoRefs.entries[cloneURL] = refCacheEntry{
+		refs: map[string]string{
+			"HEAD":                     sha,
+			"refs/heads/main":          sha,
+			"refs/heads/feature":       sha,
+			"refs/heads/feature/login": sha,
+			"refs/tags/v1.0":           sha,
+		},
+		expires: time.Now().Add(time.Minute),
+	}
+	repoRefs.mu.Unlock()
+	defer func() {
+		repoRefs.mu.Lock()
+		delete(repoRefs.entries, cloneURL)
+		repoRefs.mu.Unlock()
+	}()
+
+	tests := []struct {
+		raw      string
+		ref      string
+		filePath string
+		resolved bool
+	}{
+		// 优先匹配最长的ref
+		{"https://github.com/owner/resolve-test/blob/feature/login/src/app.go", "feature/login", "src/app.go", true},
+		{"https://github.com/owner/resolve-test/blob/feature/src/app.go", "feature", "src/app.go", true},
+		{"https://github.com/owner/resolve-test/tree/feature/login", "feature/login", "", true},
+		// 文件链接至少保留一段作为文件名
+		{"https://github.com/owner/resolve-test/blob/feature/login", "feature", "login", true},
+		{"https://github.com/owner/resolve-test/blob/unknown/branch/file", "unknown", "branch/file", false},
+		{"https://github.com/owner/resolve-test/blob/" + sha + "/file", sha, "file", true},
+	}
+	for _, tt := range tests {
+		src, err := ParseSourceURL(tt.raw)
+		if err != nil {
+			t.Fatalf("ParseSourceURL(%q): %v", tt.raw, err)
+		}
+		if err := resolveSourceRef(context.Background(), src); err != nil {
+			t.Fatalf("resolveSourceRef(%q): %v", tt.raw, err)
+		}
+		if src.Ref != tt.ref || src.FilePath != tt.filePath || src.RefResolved != tt.resolved {
+			t.Errorf("resolveSourceRef(%q) = %q, %q, %v, want %q, %q, %v",
+				tt.raw, src.Ref, src.FilePath, src.RefResolved, tt.ref, tt.filePath, tt.resolved)
+		}
+	}
+}
+
+func TestRefCacheDedupesConcurrentFetches(t *testing.T) {
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	stubUpstream(t, func(r *http.Request) (*http.Response, error) {
+		fetches.Add(1)
+		<-release
+		body := pktLine("1111111111111111111111111111111111111111 refs/heads/main\n") + "0000"
+		return stubResponse(r, http.StatusOK, nil, body), nil
+	})
+
+	cache := newRefCache()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			refs, err := cache.list(context.Background(), "https://github.com/o/r.git")
+			if err != nil || refs["refs/heads/main"] == "" {
+				t.Errorf("list = %v, %v", refs, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("upstream fetched %d times, want 1", n)
+	}
+}
+
+func TestRefCacheBounded(t *testing.T) {
+	cache := newRefCache()
+	now := time.Now()
+	cache.entries["expired"] = refCacheEntry{expires: now.Add(-time.Second)}
+	for i := 0; i < maxRefCacheEntries-1; i++ {
+		cache.entries[fmt.Sprint(i)] = refCacheEntry{expires: now.Add(time.Duration(i+1) * time.Second)}
+	}
+	cache.store("new-1", nil, now)
+	cache.store("new-2", nil, now)
+	if len(cache.entries) != maxRefCacheEntries {
+		t.Errorf("entries = %d, want %d", len(cache.entries), maxRefCacheEntries)
+	}
+	if _, ok := cache.entries["expired"]; ok {
+		t.Error("expired entry kept")
+	}
+	// 已满时淘汰最早过期的仓库
+	if _, ok := cache.entries["0"]; ok {
+		t.Error("earliest-expiring entry kept")
+	}
+	if _, ok := cache.entries["new-2"]; !ok {
+		t.Error("new entry missing")
+	}
+}
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ghcr.io 只读镜像：实现OCI Distribution v2的拉取接口，由代理完成上游的令牌认证
+const registryUpstream = "ghcr.io"
+
+var (
+	// 仓库名：小写字母数字，用 . _ - 分隔，多级用 / 分隔
+	registryNamePattern = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+	registryTagPattern  = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	registryDigestRegex = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]+$`)
+	// WWW-Authenticate 中的参数
+	authParamPattern = regexp.MustCompile(`(\w+)="([^"]*)"`)
+)
+
+// 需要转发给客户端的上游响应头
+var registryResponseHeaders = []string{
+	"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges",
+	"Docker-Content-Digest", "ETag", "Last-Modified", "Link",
+}
+
+// 拉取请求：/v2/<name>/manifests/<reference>、/v2/<name>/blobs/<digest>、/v2/<name>/tags/list
+type registryRequest struct {
+	Name      string
+	Kind      string // manifests、blobs、tags
+	Reference string
+}
+
+func parseRegistryPath(p string) (registryRequest, bool) {
+	var rr registryRequest
+	if name, ok := strings.CutSuffix(p, "/tags/list"); ok {
+		rr = registryRequest{Name: name, Kind: "tags"}
+	} else {
+		for _, kind := range []string{"manifests", "blobs"} {
+			if i := strings.LastIndex(p, "/"+kind+"/"); i > 0 {
+				rr = registryRequest{Name: p[:i], Kind: kind, Reference: p[i+len(kind)+2:]}
+				break
+			}
+		}
+	}
+	if rr.Kind == "" || !registryNamePattern.MatchString(rr.Name) {
+		return rr, false
+	}
+	switch rr.Kind {
+	case "manifests":
+		return rr, registryTagPattern.MatchString(rr.Reference) || registryDigestRegex.MatchString(rr.Reference)
+	case "blobs":
+		return rr, registryDigestRegex.MatchString(rr.Reference)
+	}
+	return rr, true
+}
+
+// OCI格式的错误响应
+func registryError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]string{{"code": code, "message": message}},
+	})
+}
+
+// ghcr.io 镜像入口：主域名下的 /v2/（作为镜像源），或映射到 ghcr.io 的虚拟主机
+func registryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	log.Printf("收到镜像仓库请求: %s %s", r.Method, r.RequestURI)
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		registryError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "只支持拉取镜像")
+		return
+	}
+	if !config.platformEnabled(platformGitHub) {
+		registryError(w, http.StatusNotFound, "UNSUPPORTED", "未启用GitHub")
+		return
+	}
+
+	p := strings.TrimPrefix(r.URL.Path, "/v2")
+	if p == "" || p == "/" {
+		// 版本检查：代理自己完成上游认证，客户端无需登录
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+		return
+	}
+	rr, ok := parseRegistryPath(strings.TrimPrefix(p, "/"))
+	if !ok {
+		registryError(w, http.StatusNotFound, "UNSUPPORTED", "不支持的镜像仓库路径")
+		return
+	}
+	serveRegistry(w, r, rr)
+}
+
+// 转发拉取请求；按digest访问的manifest和blob优先从缓存读取，并写入缓存
+func serveRegistry(w http.ResponseWriter, r *http.Request, rr registryRequest) {
+	digest := ""
+	if rr.Kind != "tags" && registryDigestRegex.MatchString(rr.Reference) {
+		digest = rr.Reference
+	}
+	if digest != "" && registryCache != nil && registryCache.serve(w, r, rr.Kind, digest) {
+		log.Printf("镜像缓存命中: %s/%s@%s", rr.Name, rr.Kind, digest)
+		return
+	}
+
+	upstreamURL := "https://" + registryUpstream + "/v2/" + rr.Name + "/" + rr.Kind + "/" + rr.Reference
+	if rr.Kind == "tags" {
+		upstreamURL = "https://" + registryUpstream + "/v2/" + rr.Name + "/tags/list"
+		if r.URL.RawQuery != "" {
+			upstreamURL += "?" + r.URL.RawQuery
+		}
+	}
+
+	header := make(http.Header)
+	for _, key := range []string{"Accept", "Range", "If-None-Match"} {
+		if values := r.Header.Values(key); len(values) > 0 {
+			header[key] = values
+		}
+	}
+	resp, err := registryFetch(r.Context(), r.Method, upstreamURL, rr.Name, header)
+	if err != nil {
+		log.Printf("镜像仓库请求失败: %v", err)
+		registryError(w, http.StatusBadGateway, "UNKNOWN", "请求上游失败")
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, key := range registryResponseHeaders {
+		if values := resp.Header.Values(key); len(values) > 0 {
+			w.Header()[key] = values
+		}
+	}
+	if digest != "" && w.Header().Get("Docker-Content-Digest") == "" {
+		w.Header().Set("Docker-Content-Digest", digest)
+	}
+	// 未在服务端跟随的重定向（如 passthrough 模式下跳转到 pkg-containers.githubusercontent.com）
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		if location, err := resp.Location(); err == nil && checkEgressURL(location) == nil {
+			if proxied, ok := proxyURLFor(r, location); ok {
+				w.Header().Set("Location", proxied)
+			}
+		}
+	}
+
+	// 按tag拉取的manifest也按响应中的digest缓存
+	cacheDigest := digest
+	if cacheDigest == "" && rr.Kind == "manifests" {
+		cacheDigest = resp.Header.Get("Docker-Content-Digest")
+	}
+	var entry *cacheEntry
+	if registryCache != nil && r.Method == http.MethodGet && resp.StatusCode == http.StatusOK &&
+		rr.Kind != "tags" && r.Header.Get("Range") == "" {
+		entry = registryCache.create(rr.Kind, cacheDigest, resp.Header.Get("Content-Type"))
+	}
+
+	w.WriteHeader(resp.StatusCode)
+	var dst io.Writer = w
+	if entry != nil {
+		dst = io.MultiWriter(w, entry)
+	}
+	if _, err := io.Copy(dst, resp.Body); err != nil {
+		log.Printf("复制镜像仓库响应失败: %v", err)
+		if entry != nil {
+			entry.abort()
+		}
+		return
+	}
+	if entry != nil {
+		entry.commit()
+	}
+	log.Printf("[%s] %s -> %s (Status: %d)", r.RemoteAddr, r.RequestURI, upstreamURL, resp.StatusCode)
+}
+
+// 上游令牌缓存，键为 scope（如 repository:owner/image:pull）
+type registryToken struct {
+	token   string
+	expires time.Time
+}
+
+var registryTokens = struct {
+	sync.Mutex
+	entries map[string]registryToken
+}{entries: make(map[string]registryToken)}
+
+// 请求上游，遇到401时按 WWW-Authenticate 获取匿名令牌后重试
+func registryFetch(ctx context.Context, method, upstreamURL, name string, header http.Header) (*http.Response, error) {
+	scope := "repository:" + name + ":pull"
+	registryTokens.Lock()
+	cached := registryTokens.entries[scope]
+	registryTokens.Unlock()
+	token := ""
+	if time.Now().Before(cached.expires) {
+		token = cached.token
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, upstreamURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		req.Header.Set("User-Agent", "ghproxy/"+Version)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := upstreamClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return resp, nil
+		}
+		challenge := resp.Header.Get("WWW-Authenticate")
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+
+		if token, err = fetchRegistryToken(ctx, challenge, scope); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// 按 Bearer realm="...",service="...",scope="..." 获取令牌
+func fetchRegistryToken(ctx context.Context, challenge, scope string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("不支持的认证方式: %q", challenge)
+	}
+	fields := make(map[string]string)
+	for _, m := range authParamPattern.FindAllStringSubmatch(params, -1) {
+		fields[m[1]] = m[2]
+	}
+	realm, err := url.Parse(fields["realm"])
+	if err != nil || fields["realm"] == "" {
+		return "", fmt.Errorf("无效的认证地址: %q", challenge)
+	}
+	if err := checkEgressURL(realm); err != nil {
+		return "", fmt.Errorf("认证地址被出站策略拒绝: %w", err)
+	}
+	query := realm.Query()
+	if fields["service"] != "" {
+		query.Set("service", fields["service"])
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	var result struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := fetchUpstreamJSON(ctx, realm.String(), &result); err != nil {
+		return "", fmt.Errorf("获取令牌失败: %w", err)
+	}
+	token := result.Token
+	if token == "" {
+		token = result.AccessToken
+	}
+	if token == "" {
+		return "", fmt.Errorf("上游未返回令牌")
+	}
+
+	ttl := time.Duration(result.ExpiresIn) * time.Second
+	if ttl <= 0 {
+		ttl = 60 * time.Second
+	}
+	now := time.Now()
+	registryTokens.Lock()
+	// 顺便清理过期的令牌
+	for k, e := range registryTokens.entries {
+		if now.After(e.expires) {
+			delete(registryTokens.entries, k)
+		}
+	}
+	registryTokens.entries[scope] = registryToken{token: token, expires: now.Add(ttl * 9 / 10)}
+	registryTokens.Unlock()
+	return token, nil
+}
+
+// 按digest存储的只读缓存：<目录>/<manifests|blobs>/<算法>/<值>，超过大小上限时删除最久未使用的文件。
+// 文件的大小和使用顺序保存在内存中，启动时扫描一次缓存目录
+type digestCache struct {
+	dir      string
+	maxBytes int64
+
+	mu    sync.Mutex
+	size  int64
+	lru   *list.List
+	items map[string]*list.Element
+}
+
+// 缓存中的一个文件
+type cachedFile struct {
+	path string
+	size int64
+}
+
+var registryCache *digestCache
+
+// 初始化镜像缓存，未配置缓存目录时不缓存
+func setupRegistryCache(cfg RegistryConfig) error {
+	if cfg.CacheDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Join(cfg.CacheDir, "tmp"), 0o755); err != nil {
+		return err
+	}
+	registryCache = newDigestCache(cfg.CacheDir, cfg.CacheMaxBytes)
+	log.Printf("镜像缓存: %d 个文件，共 %d 字节", registryCache.lru.Len(), registryCache.size)
+	return nil
+}
+
+// 扫描缓存目录建立索引，按修改时间排列使用顺序
+func newDigestCache(dir string, maxBytes int64) *digestCache {
+	c := &digestCache{dir: dir, maxBytes: maxBytes, lru: list.New(), items: make(map[string]*list.Element)}
+	type scannedFile struct {
+		cachedFile
+		modTime time.Time
+	}
+	var files []scannedFile
+	for _, kind := range []string{"manifests", "blobs"} {
+		filepath.WalkDir(filepath.Join(dir, kind), func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || strings.HasSuffix(p, ".type") {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				files = append(files, scannedFile{cachedFile{p, info.Size()}, info.ModTime()})
+			}
+			return nil
+		})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		c.items[f.path] = c.lru.PushFront(&cachedFile{f.path, f.size})
+		c.size += f.size
+	}
+	c.evict()
+	return c
+}
+
+func (c *digestCache) path(kind, digest string) string {
+	algo, value, _ := strings.Cut(digest, ":")
+	return filepath.Join(c.dir, kind, algo, value)
+}
+
+// 从缓存返回内容，未命中时返回false
+func (c *digestCache) serve(w http.ResponseWriter, r *http.Request, kind, digest string) bool {
+	p := c.path(kind, digest)
+	f, err := os.Open(p)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	contentType := "application/octet-stream"
+	if kind == "manifests" {
+		data, err := os.ReadFile(p + ".type")
+		if err != nil {
+			return false
+		}
+		contentType = string(data)
+	}
+	// 更新使用顺序；修改时间用于重启后恢复使用顺序
+	c.touch(p)
+	now := time.Now()
+	os.Chtimes(p, now, now)
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Docker-Content-Digest", digest)
+	w.Header().Set("ETag", `"`+digest+`"`)
+	http.ServeContent(w, r, "", info.ModTime(), f)
+	return true
+}
+
+// 正在写入的缓存文件，内容校验通过后才放入缓存
+type cacheEntry struct {
+	cache       *digestCache
+	file        *os.File
+	hash        hash.Hash
+	kind        string
+	digest      string
+	contentType string
+}
+
+// 创建缓存文件，只缓存sha256 digest的内容
+func (c *digestCache) create(kind, digest, contentType string) *cacheEntry {
+	algo, value, _ := strings.Cut(digest, ":")
+	if algo != "sha256" || len(value) != 64 {
+		return nil
+	}
+	f, err := os.CreateTemp(filepath.Join(c.dir, "tmp"), "layer-")
+	if err != nil {
+		log.Printf("创建镜像缓存文件失败: %v", err)
+		return nil
+	}
+	return &cacheEntry{cache: c, file: f, hash: sha256.New(), kind: kind, digest: digest, contentType: contentType}
+}
+
+func (e *cacheEntry) Write(p []byte) (int, error) {
+	e.hash.Write(p)
+	return e.file.Write(p)
+}
+
+func (e *cacheEntry) abort() {
+	e.file.Close()
+	os.Remove(e.file.Name())
+}
+
+// 校验digest后放入缓存
+func (e *cacheEntry) commit() {
+	if err := e.file.Close(); err != nil {
+		os.Remove(e.file.Name())
+		return
+	}
+	if "sha256:"+hex.EncodeToString(e.hash.Sum(nil)) != e.digest {
+		log.Printf("镜像内容与digest不一致，不缓存: %s", e.digest)
+		os.Remove(e.file.Name())
+		return
+	}
+
+	p := e.cache.path(e.kind, e.digest)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		os.Remove(e.file.Name())
+		return
+	}
+	if e.kind == "manifests" {
+		if err := os.WriteFile(p+".type", []byte(e.contentType), 0o644); err != nil {
+			os.Remove(e.file.Name())
+			return
+		}
+	}
+	info, err := os.Stat(e.file.Name())
+	if err != nil {
+		os.Remove(e.file.Name())
+		return
+	}
+	if err := os.Rename(e.file.Name(), p); err != nil {
+		os.Remove(e.file.Name())
+		return
+	}
+	e.cache.add(p, info.Size())
+}
+
+func (c *digestCache) touch(p string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[p]; ok {
+		c.lru.MoveToFront(el)
+	}
+}
+
+// 记录新写入的文件，超过大小上限时淘汰
+func (c *digestCache) add(p string, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[p]; ok {
+		c.size -= el.Value.(*cachedFile).size
+		c.lru.Remove(el)
+	}
+	c.items[p] = c.lru.PushFront(&cachedFile{p, size})
+	c.size += size
+	c.evictLocked()
+}
+
+func (c *digestCache) evict() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evictLocked()
+}
+
+// 超过大小上限时删除最久未使用的文件，调用方持有 c.mu
+func (c *digestCache) evictLocked() {
+	if c.maxBytes <= 0 {
+		return
+	}
+	for c.size > c.maxBytes && c.lru.Len() > 0 {
+		el := c.lru.Back()
+		f := el.Value.(*cachedFile)
+		c.lru.Remove(el)
+		delete(c.items, f.path)
+		c.size -= f.size
+		// 文件已被外部删除时同样从索引中移除
+		os.Remove(f.path)
+		os.Remove(f.path + ".type")
+		log.Printf("淘汰镜像缓存: %s", f.path)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 写入一个blob并提交到缓存，返回digest
+func commitTestBlob(t *testing.T, c *digestCache, content string) string {
+	t.Helper()
+	sum := sha256.Sum256([]byte(content))
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	entry := c.create("blobs", digest, "")
+	if entry == nil {
+		t.Fatal("create returned nil")
+	}
+	if _, err := entry.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	entry.commit()
+	return digest
+}
+
+func TestDigestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "tmp"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	c := newDigestCache(dir, 250)
+	a := commitTestBlob(t, c, strings.Repeat("a", 100))
+	b := commitTestBlob(t, c, strings.Repeat("b", 100))
+
+	// 访问a后，写入c时淘汰b
+	if !c.serve(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), "blobs", a) {
+		t.Fatal("serve(a) missed")
+	}
+	commitTestBlob(t, c, strings.Repeat("c", 100))
+
+	if c.size != 200 || c.lru.Len() != 2 {
+		t.Errorf("size = %d, files = %d, want 200, 2", c.size, c.lru.Len())
+	}
+	if _, err := os.Stat(c.path("blobs", b)); !os.IsNotExist(err) {
+		t.Errorf("b should be evicted, stat err = %v", err)
+	}
+	if _, err := os.Stat(c.path("blobs", a)); err != nil {
+		t.Errorf("a should be kept: %v", err)
+	}
+
+	// 重启后从缓存目录恢复索引
+	reloaded := newDigestCache(dir, 250)
+	if reloaded.size != 200 || reloaded.lru.Len() != 2 {
+		t.Errorf("reloaded size = %d, files = %d, want 200, 2", reloaded.size, reloaded.lru.Len())
+	}
+}
+
+func TestDigestCacheRejectsDigestMismatch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "tmp"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	c := newDigestCache(dir, 1<<20)
+	entry := c.create("blobs", "sha256:"+strings.Repeat("0", 64), "")
+	entry.Write([]byte("content"))
+	entry.commit()
+	if c.size != 0 || c.lru.Len() != 0 {
+		t.Errorf("size = %d, files = %d, want empty cache", c.size, c.lru.Len())
+	}
+}
+
+func TestRegistryTokensPruned(t *testing.T) {
+	registryTokens.Lock()
+	registryTokens.entries["repository:old/image:pull"] = registryToken{token: "old", expires: time.Now().Add(-time.Minute)}
+	registryTokens.Unlock()
+
+	oldClient := upstreamClient
+	defer func() { upstreamClient = oldClient }()
+	upstreamClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		body := io.NopCloser(strings.NewReader(`{"token":"new","expires_in":300}`))
+		return &http.Response{StatusCode: http.StatusOK, Body: body, Header: make(http.Header), Request: r}, nil
+	})}
+
+	challenge := `Bearer realm="https://ghcr.io/token",service="ghcr.io"`
+	if _, err := fetchRegistryToken(context.Background(), challenge, "repository:new/image:pull"); err != nil {
+		t.Fatalf("fetchRegistryToken: %v", err)
+	}
+	registryTokens.Lock()
+	defer registryTokens.Unlock()
+	if _, ok := registryTokens.entries["repository:old/image:pull"]; ok {
+		t.Error("expired token not pruned")
+	}
+	if registryTokens.entries["repository:new/image:pull"].token != "new" {
+		t.Error("new token not cached")
+	}
+}
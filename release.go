@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 最新版本下载：/latest/github.com/<用户>/<仓库>/<附件通配符>、/latest/gitlab.com/<组>/<项目>/<附件通配符>，
+// 也可以使用 gh/、gl/ 短前缀。通过上游API查找最新的正式版本（不含预发布），按通配符或正则表达式匹配附件，
+// 经过代理下载，版本标签在 X-Release-Tag 响应头中返回。使用 ?regex=<正则> 时路径中不写附件名
+
+// 最新版本的缓存时间，发布新版本后最多延迟这么久生效
+const latestReleaseCacheTTL = time.Minute
+
+var (
+	// 仓库不存在或没有正式版本（GitHub对这两种情况都返回404）
+	errNoRelease = errors.New("仓库不存在或没有正式版本")
+	// 没有匹配的附件
+	errAssetNotFound = errors.New("没有匹配的附件")
+	// 匹配到多个附件
+	errAssetAmbiguous = errors.New("匹配到多个附件")
+)
+
+// 语义化版本的预发布标签，如 v1.2.0-rc.1、2.0.0-beta。GitLab的发布没有预发布标记，按标签判断
+var prereleaseTagPattern = regexp.MustCompile(`^v?\d+(\.\d+)*-`)
+
+// ReleaseAsset 版本附件
+type ReleaseAsset struct {
+	Name string `json:"name"`
+	Size int64  `json:"size,omitempty"`
+	// 加速下载链接，附件不在支持的域名上时为空
+	URL string `json:"url,omitempty"`
+	// 原始下载链接
+	SourceURL string `json:"source_url"`
+}
+
+// Release 发布的版本
+type Release struct {
+	Tag         string         `json:"tag"`
+	Name        string         `json:"name,omitempty"`
+	PublishedAt string         `json:"published_at,omitempty"`
+	Assets      []ReleaseAsset `json:"assets"`
+}
+
+// LatestReleaseResponse 最新版本接口的响应
+type LatestReleaseResponse struct {
+	Success bool `json:"success"`
+	// 仓库主页地址
+	Repo    string   `json:"repo,omitempty"`
+	Release *Release `json:"release,omitempty"`
+	// 按 asset 或 regex 参数匹配到的附件
+	Asset *ReleaseAsset `json:"asset,omitempty"`
+	Error string        `json:"error,omitempty"`
+}
+
+// 附件名匹配规则：通配符（path.Match 语法）或正则表达式
+type assetMatcher struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+func newAssetMatcher(glob, expr string) (*assetMatcher, error) {
+	if expr != "" {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("无效的正则表达式: %v", err)
+		}
+		return &assetMatcher{re: re}, nil
+	}
+	if _, err := path.Match(glob, ""); err != nil {
+		return nil, fmt.Errorf("无效的通配符 %q", glob)
+	}
+	return &assetMatcher{glob: glob}, nil
+}
+
+func (m *assetMatcher) match(name string) bool {
+	if m.re != nil {
+		return m.re.MatchString(name)
+	}
+	ok, _ := path.Match(m.glob, name)
+	return ok
+}
+
+// 在版本的附件中查找唯一匹配的附件
+func (m *assetMatcher) find(release *Release) (*ReleaseAsset, error) {
+	var matched []ReleaseAsset
+	for _, asset := range release.Assets {
+		if m.match(asset.Name) {
+			matched = append(matched, asset)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return nil, fmt.Errorf("%w（版本 %s）", errAssetNotFound, release.Tag)
+	case 1:
+		return &matched[0], nil
+	}
+	names := make([]string, len(matched))
+	for i, asset := range matched {
+		names[i] = asset.Name
+	}
+	return nil, fmt.Errorf("%w（版本 %s）: %s", errAssetAmbiguous, release.Tag, strings.Join(names, ", "))
+}
+
+// 解析仓库：github.com/<用户>/<仓库>、gitlab.com/<组>/<项目>，可以带 https:// 或使用 gh/、gl/ 短前缀
+func parseReleaseRepo(spec string) (*SourceURL, error) {
+	spec = strings.Trim(strings.TrimPrefix(strings.TrimPrefix(spec, "https://"), "http://"), "/")
+	first, rest, _ := strings.Cut(spec, "/")
+	if platform, ok := shortPathPlatforms[first]; ok {
+		if prefix, ok := gitInsteadOfPrefixes[platform]; ok {
+			spec = strings.TrimPrefix(prefix, "https://") + rest
+		}
+	}
+	u, err := url.Parse("https://" + spec)
+	if err != nil {
+		return nil, errUnknownSource
+	}
+	src, err := parseSourceURL(u)
+	if err != nil || src.Kind != SourceRepo {
+		return nil, errUnknownSource
+	}
+	if src.Platform != platformGitHub && src.Platform != platformGitLab {
+		return nil, fmt.Errorf("只支持GitHub、GitLab仓库")
+	}
+	if !config.platformEnabled(src.Platform) {
+		return nil, fmt.Errorf("未启用的平台: %s", src.Platform)
+	}
+	return src, nil
+}
+
+// 最新版本查询结果缓存
+type releaseCacheEntry struct {
+	release *Release
+	expires time.Time
+}
+
+var latestReleases = struct {
+	sync.Mutex
+	entries map[string]releaseCacheEntry
+}{entries: make(map[string]releaseCacheEntry)}
+
+// 查询仓库最新的正式版本，带短期缓存
+func latestRelease(ctx context.Context, src *SourceURL) (*Release, error) {
+	key := src.RepoURL()
+	now := time.Now()
+	latestReleases.Lock()
+	entry, ok := latestReleases.entries[key]
+	latestReleases.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.release, nil
+	}
+
+	var release *Release
+	var err error
+	switch src.Platform {
+	case platformGitHub:
+		release, err = fetchGitHubLatestRelease(ctx, src)
+	case platformGitLab:
+		release, err = fetchGitLabLatestRelease(ctx, src)
+	default:
+		err = errUnknownSource
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	latestReleases.Lock()
+	// 顺便清理过期的缓存
+	for k, e := range latestReleases.entries {
+		if now.After(e.expires) {
+			delete(latestReleases.entries, k)
+		}
+	}
+	latestReleases.entries[key] = releaseCacheEntry{release: release, expires: now.Add(latestReleaseCacheTTL)}
+	latestReleases.Unlock()
+	return release, nil
+}
+
+// GitHub的 releases/latest 只返回最新的正式版本，不含预发布和草稿
+func fetchGitHubLatestRelease(ctx context.Context, src *SourceURL) (*Release, error) {
+	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/latest",
+		url.PathEscape(src.Namespace), url.PathEscape(src.Repo))
+	var result struct {
+		TagName     string `json:"tag_name"`
+		Name        string `json:"name"`
+		PublishedAt string `json:"published_at"`
+		Assets      []struct {
+			Name               string `json:"name"`
+			Size               int64  `json:"size"`
+			BrowserDownloadURL string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+	if err := fetchUpstreamJSON(ctx, apiURL, &result); err != nil {
+		if errors.Is(err, errUpstreamNotFound) {
+			return nil, errNoRelease
+		}
+		return nil, err
+	}
+
+	release := &Release{Tag: result.TagName, Name: result.Name, PublishedAt: result.PublishedAt, Assets: []ReleaseAsset{}}
+	for _, asset := range result.Assets {
+		release.Assets = append(release.Assets, ReleaseAsset{Name: asset.Name, Size: asset.Size, SourceURL: asset.BrowserDownloadURL})
+	}
+	return release, nil
+}
+
+// GitLab按发布时间倒序列出版本，跳过未来发布的版本和预发布标签。附件包括发布链接和源码归档
+func fetchGitLabLatestRelease(ctx context.Context, src *SourceURL) (*Release, error) {
+	apiURL := fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/releases?order_by=released_at&sort=desc&per_page=100",
+		url.PathEscape(src.RepoID()))
+	var results []struct {
+		TagName    string `json:"tag_name"`
+		Name       string `json:"name"`
+		ReleasedAt string `json:"released_at"`
+		Upcoming   bool   `json:"upcoming_release"`
+		Assets     struct {
+			Links []struct {
+				Name           string `json:"name"`
+				URL            string `json:"url"`
+				DirectAssetURL string `json:"direct_asset_url"`
+			} `json:"links"`
+			Sources []struct {
+				URL string `json:"url"`
+			} `json:"sources"`
+		} `json:"assets"`
+	}
+	if err := fetchUpstreamJSON(ctx, apiURL, &results); err != nil {
+		if errors.Is(err, errUpstreamNotFound) {
+			return nil, errNoRelease
+		}
+		return nil, err
+	}
+
+	for _, result := range results {
+		if result.Upcoming || prereleaseTagPattern.MatchString(result.TagName) {
+			continue
+		}
+		release := &Release{Tag: result.TagName, Name: result.Name, PublishedAt: result.ReleasedAt, Assets: []ReleaseAsset{}}
+		for _, link := range result.Assets.Links {
+			// direct_asset_url 是 gitlab.com 上的固定地址，重定向到链接的实际地址
+			sourceURL := link.DirectAssetURL
+			if sourceURL == "" {
+				sourceURL = link.URL
+			}
+			release.Assets = append(release.Assets, ReleaseAsset{Name: link.Name, SourceURL: sourceURL})
+		}
+		for _, source := range result.Assets.Sources {
+			release.Assets = append(release.Assets, ReleaseAsset{Name: path.Base(source.URL), SourceURL: source.URL})
+		}
+		return release, nil
+	}
+	return nil, errNoRelease
+}
+
+// 为附件生成加速链接，不符合出站策略的附件保持为空
+func proxyReleaseAssets(r *http.Request, release *Release) *Release {
+	proxied := *release
+	proxied.Assets = make([]ReleaseAsset, len(release.Assets))
+	for i, asset := range release.Assets {
+		if target, err := url.Parse(asset.SourceURL); err == nil && checkEgressURL(target) == nil {
+			asset.URL, _ = proxyURLFor(r, target)
+		}
+		proxied.Assets[i] = asset
+	}
+	return &proxied
+}
+
+// 查询错误对应的HTTP状态码
+func releaseErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errNoRelease), errors.Is(err, errAssetNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errAssetAmbiguous):
+		return http.StatusConflict
+	}
+	return http.StatusBadGateway
+}
+
+// 最新版本下载入口：GET /latest/<仓库>/<附件通配符>、/latest/<仓库>?regex=<正则>
+func latestReleaseHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("收到最新版本请求: %s", r.RequestURI)
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "不支持的请求方法: "+r.Method, http.StatusMethodNotAllowed)
+		return
+	}
+
+	spec := strings.TrimPrefix(r.URL.Path, "/latest/")
+	expr := r.URL.Query().Get("regex")
+	glob := ""
+	if expr == "" {
+		if i := strings.LastIndex(spec, "/"); i >= 0 {
+			spec, glob = spec[:i], spec[i+1:]
+		}
+		if glob == "" {
+			http.Error(w, "缺少附件名，格式: /latest/github.com/用户/仓库/附件通配符", http.StatusBadRequest)
+			return
+		}
+	}
+	matcher, err := newAssetMatcher(glob, expr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	src, err := parseReleaseRepo(spec)
+	if err != nil {
+		http.Error(w, "无法识别的仓库: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	release, err := latestRelease(r.Context(), src)
+	if err != nil {
+		http.Error(w, "查询最新版本失败: "+err.Error(), releaseErrorStatus(err))
+		return
+	}
+	asset, err := matcher.find(release)
+	if err != nil {
+		http.Error(w, err.Error(), releaseErrorStatus(err))
+		return
+	}
+	target, err := url.Parse(asset.SourceURL)
+	if err != nil {
+		http.Error(w, "无效的附件地址: "+asset.SourceURL, http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("X-Release-Tag", release.Tag)
+	w.Header().Set("X-Release-Asset", asset.Name)
+	serveProxy(latestReleaseWriter{w}, r, target, false)
+}
+
+// 最新版本的地址在发布新版本后指向新的文件，响应不能按上游的缓存时间被下游缓存
+type latestReleaseWriter struct {
+	http.ResponseWriter
+}
+
+func (w latestReleaseWriter) WriteHeader(status int) {
+	w.Header().Set("Cache-Control", "no-cache")
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w latestReleaseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// 最新版本接口：GET /api/releases/latest?repo=github.com/用户/仓库[&asset=通配符|&regex=正则]
+func latestReleaseAPI(w http.ResponseWriter, r *http.Request) {
+	setAPIHeaders(w, "GET, OPTIONS")
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	fail := func(msg string) {
+		json.NewEncoder(w).Encode(LatestReleaseResponse{Success: false, Error: msg})
+	}
+	if r.Method != "GET" && r.Method != "HEAD" {
+		fail("只支持GET请求")
+		return
+	}
+
+	query := r.URL.Query()
+	src, err := parseReleaseRepo(query.Get("repo"))
+	if err != nil {
+		fail("无法识别的仓库: " + err.Error())
+		return
+	}
+	var matcher *assetMatcher
+	if query.Get("asset") != "" || query.Get("regex") != "" {
+		if matcher, err = newAssetMatcher(query.Get("asset"), query.Get("regex")); err != nil {
+			fail(err.Error())
+			return
+		}
+	}
+
+	release, err := latestRelease(r.Context(), src)
+	if err != nil {
+		fail("查询最新版本失败: " + err.Error())
+		return
+	}
+	response := LatestReleaseResponse{Success: true, Repo: src.RepoURL(), Release: proxyReleaseAssets(r, release)}
+	if matcher != nil {
+		if response.Asset, err = matcher.find(response.Release); err != nil {
+			fail(err.Error())
+			return
+		}
+	}
+	json.NewEncoder(w).Encode(response)
+}
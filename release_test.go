@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 清空最新版本缓存，测试结束后再次清空
+func resetLatestReleases(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		latestReleases.Lock()
+		latestReleases.entries = make(map[string]releaseCacheEntry)
+		latestReleases.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestAssetMatcher(t *testing.T) {
+	release := &Release{Tag: "v1.0", Assets: []ReleaseAsset{
+		{Name: "app-linux-amd64.tar.gz"},
+		{Name: "app-linux-arm64.tar.gz"},
+		{Name: "app-darwin-amd64.tar.gz"},
+		{Name: "checksums.txt"},
+	}}
+	tests := []struct {
+		glob, expr string
+		want       string
+		err        error
+	}{
+		{glob: "*linux-amd64*", want: "app-linux-amd64.tar.gz"},
+		{glob: "checksums.txt", want: "checksums.txt"},
+		{expr: `darwin-(amd|arm)64`, want: "app-darwin-amd64.tar.gz"},
+		// 同时给出时使用正则表达式
+		{glob: "checksums.txt", expr: `^app-linux-arm64\.`, want: "app-linux-arm64.tar.gz"},
+		{glob: "*windows*", err: errAssetNotFound},
+		{expr: `\.exe$`, err: errAssetNotFound},
+		{glob: "app-linux-*", err: errAssetAmbiguous},
+		{expr: `amd64`, err: errAssetAmbiguous},
+	}
+	for _, tt := range tests {
+		m, err := newAssetMatcher(tt.glob, tt.expr)
+		if err != nil {
+			t.Fatalf("newAssetMatcher(%q, %q): %v", tt.glob, tt.expr, err)
+		}
+		asset, err := m.find(release)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("find(%q, %q) err = %v, want %v", tt.glob, tt.expr, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || asset.Name != tt.want {
+			t.Errorf("find(%q, %q) = %v, %v; want %s", tt.glob, tt.expr, asset, err, tt.want)
+		}
+	}
+
+	for _, bad := range [][2]string{{"[", ""}, {"", "("}} {
+		if _, err := newAssetMatcher(bad[0], bad[1]); err == nil {
+			t.Errorf("newAssetMatcher(%q, %q) 应返回错误", bad[0], bad[1])
+		}
+	}
+}
+
+func TestParseReleaseRepo(t *testing.T) {
+	tests := []struct {
+		spec     string
+		platform string
+		repoID   string
+	}{
+		{"github.com/o/r", platformGitHub, "o/r"},
+		{"https://github.com/o/r/", platformGitHub, "o/r"},
+		{"gh/o/r", platformGitHub, "o/r"},
+		{"gitlab.com/group/project", platformGitLab, "group/project"},
+		{"gl/group/sub/project", platformGitLab, "group/sub/project"},
+		{"huggingface.co/org/model", "", ""},
+		{"github.com/o/r/blob/main/a.txt", "", ""},
+		{"github.com/o", "", ""},
+		{"example.com/o/r", "", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		src, err := parseReleaseRepo(tt.spec)
+		if tt.platform == "" {
+			if err == nil {
+				t.Errorf("parseReleaseRepo(%q) = %+v, want 错误", tt.spec, src)
+			}
+			continue
+		}
+		if err != nil || src.Platform != tt.platform || src.RepoID() != tt.repoID {
+			t.Errorf("parseReleaseRepo(%q) = %+v, %v; want %s %s", tt.spec, src, err, tt.platform, tt.repoID)
+		}
+	}
+
+	withConfig(t, func(cfg *Config) { cfg.Platforms = []string{platformGitHub} })
+	if _, err := parseReleaseRepo("gl/group/project"); err == nil {
+		t.Error("未启用的平台应返回错误")
+	}
+}
+
+func TestPrereleaseTagPattern(t *testing.T) {
+	for tag, want := range map[string]bool{
+		"v1.2.0-rc.1": true,
+		"2.0.0-beta":  true,
+		"v1-alpha":    true,
+		"v1.2.0":      false,
+		"release-1":   false,
+		"nightly":     false,
+	} {
+		if got := prereleaseTagPattern.MatchString(tag); got != want {
+			t.Errorf("prereleaseTagPattern(%q) = %v, want %v", tag, got, want)
+		}
+	}
+}
+
+func TestReleaseErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errNoRelease, http.StatusNotFound},
+		{fmt.Errorf("%w（版本 v1）", errAssetNotFound), http.StatusNotFound},
+		{fmt.Errorf("%w（版本 v1）: a, b", errAssetAmbiguous), http.StatusConflict},
+		{errors.New("上游返回 500"), http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		if got := releaseErrorStatus(tt.err); got != tt.want {
+			t.Errorf("releaseErrorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+// GitLab没有预发布标记：跳过未来发布的版本和预发布标签
+func TestFetchGitLabLatestRelease(t *testing.T) {
+	stubUpstream(t, func(r *http.Request) (*http.Response, error) {
+		if r.URL.EscapedPath() != "/api/v4/projects/group%2Fproject/releases" {
+			return stubResponse(r, http.StatusNotFound, nil, ""), nil
+		}
+		return stubResponse(r, http.StatusOK, nil, `[
+			{"tag_name": "v3.0.0", "upcoming_release": true},
+			{"tag_name": "v2.0.0-rc.1"},
+			{"tag_name": "v1.5.0", "name": "1.5", "released_at": "2024-01-01T00:00:00Z", "assets": {
+				"links": [
+					{"name": "app.tar.gz", "url": "https://example.com/app.tar.gz", "direct_asset_url": "https://gitlab.com/group/project/-/releases/v1.5.0/downloads/app.tar.gz"},
+					{"name": "notes.txt", "url": "https://gitlab.com/group/project/-/raw/v1.5.0/notes.txt"}
+				],
+				"sources": [{"url": "https://gitlab.com/group/project/-/archive/v1.5.0/project-v1.5.0.zip"}]
+			}},
+			{"tag_name": "v1.4.0"}
+		]`), nil
+	})
+
+	release, err := fetchGitLabLatestRelease(context.Background(), &SourceURL{Platform: platformGitLab, Host: "gitlab.com", Namespace: "group", Repo: "project", Kind: SourceRepo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ReleaseAsset{
+		{Name: "app.tar.gz", SourceURL: "https://gitlab.com/group/project/-/releases/v1.5.0/downloads/app.tar.gz"},
+		{Name: "notes.txt", SourceURL: "https://gitlab.com/group/project/-/raw/v1.5.0/notes.txt"},
+		{Name: "project-v1.5.0.zip", SourceURL: "https://gitlab.com/group/project/-/archive/v1.5.0/project-v1.5.0.zip"},
+	}
+	if release.Tag != "v1.5.0" || len(release.Assets) != len(want) {
+		t.Fatalf("release = %+v", release)
+	}
+	for i := range want {
+		if release.Assets[i] != want[i] {
+			t.Errorf("assets[%d] = %+v, want %+v", i, release.Assets[i], want[i])
+		}
+	}
+}
+
+// 模拟GitHub：o/r 的最新正式版本为 v1.0（releases/latest 不返回预发布和草稿），o/empty 没有正式版本
+func stubGitHubReleases(t *testing.T) *[]string {
+	t.Helper()
+	resetLatestReleases(t)
+	var requests []string
+	stubUpstream(t, func(r *http.Request) (*http.Response, error) {
+		requests = append(requests, r.URL.String())
+		switch r.URL.String() {
+		case "https://api.github.com/repos/o/r/releases/latest":
+			return stubResponse(r, http.StatusOK, nil, `{"tag_name": "v1.0", "assets": [
+				{"name": "app-linux-amd64.tar.gz", "size": 4, "browser_download_url": "https://github.com/o/r/releases/download/v1.0/app-linux-amd64.tar.gz"},
+				{"name": "app-linux-arm64.tar.gz", "size": 4, "browser_download_url": "https://github.com/o/r/releases/download/v1.0/app-linux-arm64.tar.gz"}
+			]}`), nil
+		case "https://github.com/o/r/releases/download/v1.0/app-linux-amd64.tar.gz":
+			return stubResponse(r, http.StatusOK, http.Header{"Cache-Control": {"max-age=300"}}, "data"), nil
+		}
+		return stubResponse(r, http.StatusNotFound, nil, `{"message": "Not Found"}`), nil
+	})
+	return &requests
+}
+
+func TestLatestReleaseHandler(t *testing.T) {
+	requests := stubGitHubReleases(t)
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/latest/gh/o/r/*linux-amd64*", http.StatusOK, "data"},
+		{"/latest/github.com/o/r?regex=amd64", http.StatusOK, "data"},
+		{"/latest/github.com/o/r/app-linux-*", http.StatusConflict, ""},
+		{"/latest/github.com/o/r/*.exe", http.StatusNotFound, ""},
+		{"/latest/github.com/o/empty/*", http.StatusNotFound, ""},
+		{"/latest/github.com/o/r/", http.StatusBadRequest, ""},
+		{"/latest/github.com/o/r/[", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		latestReleaseHandler(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d (%s)", tt.path, w.Code, tt.status, w.Body.String())
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		h := w.Header()
+		if w.Body.String() != tt.body || h.Get("X-Release-Tag") != "v1.0" || h.Get("X-Release-Asset") != "app-linux-amd64.tar.gz" {
+			t.Errorf("%s: body %q, headers %v", tt.path, w.Body.String(), h)
+		}
+		// 上游的缓存时间被覆盖，发布新版本后立即生效
+		if h.Get("Cache-Control") != "no-cache" {
+			t.Errorf("%s: Cache-Control = %q", tt.path, h.Get("Cache-Control"))
+		}
+	}
+
+	// 最新版本的查询结果有短期缓存
+	apiCalls := 0
+	for _, u := range *requests {
+		if u == "https://api.github.com/repos/o/r/releases/latest" {
+			apiCalls++
+		}
+	}
+	if apiCalls != 1 {
+		t.Errorf("releases/latest 请求了 %d 次，want 1", apiCalls)
+	}
+}
+
+func TestLatestReleaseAPI(t *testing.T) {
+	stubGitHubReleases(t)
+
+	r := httptest.NewRequest("GET", "/api/releases/latest?repo=gh/o/r&asset=*arm64*", nil)
+	r.Host = "proxy.local"
+	w := httptest.NewRecorder()
+	latestReleaseAPI(w, r)
+	var resp LatestReleaseResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Success || resp.Repo != "https://github.com/o/r" || resp.Release.Tag != "v1.0" || len(resp.Release.Assets) != 2 {
+		t.Fatalf("resp = %+v", resp)
+	}
+	want := "http://proxy.local/https://github.com/o/r/releases/download/v1.0/app-linux-arm64.tar.gz"
+	if resp.Asset == nil || resp.Asset.URL != want {
+		t.Errorf("asset = %+v, want URL %s", resp.Asset, want)
+	}
+
+	w = httptest.NewRecorder()
+	latestReleaseAPI(w, httptest.NewRequest("GET", "/api/releases/latest?repo=github.com/o/empty", nil))
+	resp = LatestReleaseResponse{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Success || !strings.Contains(resp.Error, errNoRelease.Error()) {
+		t.Errorf("没有正式版本: %+v", resp)
+	}
+}
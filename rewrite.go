@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// 脚本改写模式的路径前缀，如 /rewrite/https://github.com/user/repo/blob/main/install.sh
+const rewritePathPrefix = "rewrite/"
+
+// 脚本改写的查询参数，如 ?rewrite=1
+const rewriteQueryParam = "rewrite"
+
+// 嗅探是否为文本内容时读取的字节数
+const rewriteSniffBytes = 8192
+
+// 匹配文本中的URL，遇到空白、引号、括号等字符结束
+var textURLPattern = regexp.MustCompile("https?://[^\\s\"'`<>()\\[\\]\\\\]+")
+
+// URL末尾通常属于句子而不是URL的标点
+const urlTrailingPunct = ".,;:!?"
+
+// URLRewrite 文本中一个URL的改写结果
+type URLRewrite struct {
+	Original string `json:"original"`
+	// 改写后的代理链接
+	Proxied string `json:"proxied,omitempty"`
+	// 代理实际请求的上游地址（经过 convertURL 转换）
+	Target string `json:"target,omitempty"`
+	// 未改写的原因
+	Reason string `json:"reason,omitempty"`
+}
+
+// 可改写的文本类型
+var rewritableContentTypes = map[string]bool{
+	"text/plain":                true,
+	"text/x-shellscript":        true,
+	"text/x-sh":                 true,
+	"text/x-python":             true,
+	"text/x-script.python":      true,
+	"application/x-sh":          true,
+	"application/x-shellscript": true,
+	"application/x-python":      true,
+	"application/x-powershell":  true,
+}
+
+// 可改写的脚本扩展名，用于上游返回 application/octet-stream 的情况
+var rewritableExtensions = map[string]bool{
+	".sh": true, ".bash": true, ".zsh": true,
+	".ps1": true, ".psm1": true,
+	".py": true,
+}
+
+// 从请求路径中取出改写模式前缀
+func takeRewritePrefix(requestPath string) (string, bool) {
+	if strings.HasPrefix(requestPath, rewritePathPrefix) {
+		return strings.TrimPrefix(requestPath, rewritePathPrefix), true
+	}
+	return requestPath, false
+}
+
+// 从目标URL中取出改写参数，其余查询参数保持原样和原顺序
+func takeRewriteQuery(target *url.URL) bool {
+	if target.RawQuery == "" {
+		return false
+	}
+	found := false
+	var kept []string
+	for _, pair := range strings.Split(target.RawQuery, "&") {
+		key, value, _ := strings.Cut(pair, "=")
+		if key == rewriteQueryParam {
+			found = value == "" || value == "1" || value == "true"
+			continue
+		}
+		kept = append(kept, pair)
+	}
+	target.RawQuery = strings.Join(kept, "&")
+	return found
+}
+
+// 根据响应类型和文件名判断是否可能是脚本
+func isRewritableResponse(resp *http.Response, target *url.URL) bool {
+	if resp.Header.Get("Content-Encoding") != "" && resp.Header.Get("Content-Encoding") != "identity" {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if rewritableContentTypes[mediaType] {
+		return true
+	}
+	if mediaType != "" && mediaType != "application/octet-stream" {
+		return false
+	}
+	name := path.Base(target.Path)
+	return rewritableExtensions[strings.ToLower(path.Ext(name))] || name == "Dockerfile" || strings.HasPrefix(name, "Dockerfile.")
+}
+
+// 检查内容开头是否为文本：不含NUL字节且是有效的UTF-8
+func looksLikeText(head []byte) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return false
+	}
+	// 截断处可能落在多字节字符中间，去掉末尾不完整的字符后再校验
+	for i := 0; i < utf8.UTFMax && len(head) > 0 && !utf8.Valid(head); i++ {
+		head = head[:len(head)-1]
+	}
+	return utf8.Valid(head)
+}
+
+// 准备改写：嗅探响应内容，确认是文本后返回用于读取的Reader
+func prepareScriptRewrite(resp *http.Response, target *url.URL) (*bufio.Reader, bool) {
+	if resp.StatusCode != http.StatusOK || !isRewritableResponse(resp, target) {
+		return nil, false
+	}
+	reader := bufio.NewReaderSize(resp.Body, rewriteSniffBytes)
+	head, err := reader.Peek(rewriteSniffBytes)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return reader, false
+	}
+	if !looksLikeText(head) {
+		return reader, false
+	}
+
+	// 内容长度会变化，改为分块传输；原有的校验头也不再有效
+	resp.Header.Del("Content-Length")
+	resp.Header.Del("Content-MD5")
+	resp.Header.Del("Accept-Ranges")
+	resp.Header.Del("ETag")
+	resp.ContentLength = -1
+	return reader, true
+}
+
+// 把文本中支持域名的URL改写为经过代理的链接，URL本身保持不变，只在前面插入代理地址。
+// record 不为空时会收到每个URL的处理结果，改写时 changed 为 true
+func rewriteTextURLs(text []byte, baseURL string, record func(rw URLRewrite, changed bool)) []byte {
+	matches := textURLPattern.FindAllIndex(text, -1)
+	if matches == nil {
+		return text
+	}
+	var out bytes.Buffer
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		for end > start && strings.IndexByte(urlTrailingPunct, text[end-1]) >= 0 {
+			end--
+		}
+		original := string(text[start:end])
+
+		rw, ok := proxiedURL(original, baseURL)
+		if record != nil {
+			record(rw, ok)
+		}
+		if !ok {
+			continue
+		}
+		out.Write(text[last:start])
+		out.WriteString(baseURL)
+		out.WriteByte('/')
+		last = start
+	}
+	out.Write(text[last:])
+	return out.Bytes()
+}
+
+// 计算单个URL的代理链接，与代理处理器使用同样的出站策略和转换规则
+func proxiedURL(original, baseURL string) (URLRewrite, bool) {
+	rw := URLRewrite{Original: original}
+	if strings.HasPrefix(original, baseURL+"/") {
+		rw.Reason = "已经是代理链接"
+		return rw, false
+	}
+	u, err := url.Parse(original)
+	if err != nil {
+		rw.Reason = "URL格式无效"
+		return rw, false
+	}
+	if !isSupportedDomain(normalizeHost(u.Host)) {
+		rw.Reason = "不支持的域名"
+		return rw, false
+	}
+	if err := checkEgressURL(u); err != nil {
+		rw.Reason = strings.TrimPrefix(err.Error(), errEgressDenied.Error()+": ")
+		return rw, false
+	}
+	rw.Proxied = baseURL + "/" + original
+	rw.Target = convertURL(u).String()
+	return rw, true
+}
+
+// 逐行改写脚本内容并写入客户端
+func streamScriptRewrite(dst io.Writer, src *bufio.Reader, baseURL string) error {
+	for {
+		line, err := src.ReadBytes('\n')
+		if len(line) > 0 {
+			if _, werr := dst.Write(rewriteTextURLs(line, baseURL, nil)); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestTakeRewriteQuery(t *testing.T) {
+	tests := []struct {
+		rawQuery string
+		want     bool
+		kept     string
+	}{
+		{"", false, ""},
+		{"rewrite=1", true, ""},
+		{"rewrite", true, ""},
+		{"rewrite=true", true, ""},
+		{"rewrite=0", false, ""},
+		// 其余参数保持原样和原顺序，包括 + 和 %2F
+		{"a=1&rewrite=1&b=x+y%2Fz", true, "a=1&b=x+y%2Fz"},
+		{"X-Amz-Signature=abc%2B&rewriter=1", false, "X-Amz-Signature=abc%2B&rewriter=1"},
+	}
+	for _, tt := range tests {
+		u := &url.URL{Scheme: "https", Host: "github.com", Path: "/o/r/raw/main/install.sh", RawQuery: tt.rawQuery}
+		if got := takeRewriteQuery(u); got != tt.want || u.RawQuery != tt.kept {
+			t.Errorf("takeRewriteQuery(%q) = %v, %q; want %v, %q", tt.rawQuery, got, u.RawQuery, tt.want, tt.kept)
+		}
+	}
+}
+
+func TestLooksLikeText(t *testing.T) {
+	tests := []struct {
+		head []byte
+		want bool
+	}{
+		{[]byte("#!/bin/sh\necho hi\n"), true},
+		{[]byte("echo 你好"), true},
+		// 截断在多字节字符中间
+		{[]byte("echo 你好")[:len("echo 你")+1], true},
+		{[]byte("\x7fELF\x02\x01\x01\x00"), false},
+		{[]byte{0x1f, 0x8b, 0x08, 0x00}, false},
+		{[]byte{0xff, 0xfe, 'a', 'b', 'c', 'd', 'e'}, false},
+	}
+	for _, tt := range tests {
+		if got := looksLikeText(tt.head); got != tt.want {
+			t.Errorf("looksLikeText(%q) = %v, want %v", tt.head, got, tt.want)
+		}
+	}
+}
+
+func TestPrepareScriptRewrite(t *testing.T) {
+	target, _ := url.Parse("https://raw.githubusercontent.com/o/r/main/install.sh")
+	tests := []struct {
+		name        string
+		contentType string
+		encoding    string
+		body        string
+		want        bool
+	}{
+		{"shell script", "text/plain; charset=utf-8", "", "#!/bin/sh\ncurl https://github.com/x\n", true},
+		{"octet-stream with .sh name", "application/octet-stream", "", "echo hi\n", true},
+		{"binary", "application/octet-stream", "", "\x7fELF\x00\x00", false},
+		{"html", "text/html", "", "<html></html>", false},
+		{"compressed", "text/plain", "gzip", "\x1f\x8b\x08\x00", false},
+	}
+	for _, tt := range tests {
+		header := http.Header{
+			"Content-Type":   {tt.contentType},
+			"Content-Length": {"42"},
+			"Etag":           {`"abc"`},
+			"Accept-Ranges":  {"bytes"},
+			"Last-Modified":  {"Mon, 01 Jan 2024 00:00:00 GMT"},
+		}
+		if tt.encoding != "" {
+			header.Set("Content-Encoding", tt.encoding)
+		}
+		resp := stubResponse(nil, http.StatusOK, header, tt.body)
+		reader, ok := prepareScriptRewrite(resp, target)
+		if ok != tt.want {
+			t.Errorf("%s: prepareScriptRewrite = %v, want %v", tt.name, ok, tt.want)
+			continue
+		}
+		if ok {
+			for _, name := range []string{"Content-Length", "ETag", "Accept-Ranges"} {
+				if resp.Header.Get(name) != "" {
+					t.Errorf("%s: %s kept after rewrite", tt.name, name)
+				}
+			}
+			if resp.Header.Get("Last-Modified") == "" || resp.ContentLength != -1 {
+				t.Errorf("%s: Last-Modified = %q, ContentLength = %d", tt.name, resp.Header.Get("Last-Modified"), resp.ContentLength)
+			}
+		} else {
+			if resp.Header.Get("Content-Length") != "42" || resp.Header.Get("ETag") == "" || resp.Header.Get("Content-Encoding") != tt.encoding {
+				t.Errorf("%s: headers changed without rewrite: %v", tt.name, resp.Header)
+			}
+		}
+		// 嗅探读取的内容不能丢失
+		if reader != nil {
+			var buf bytes.Buffer
+			buf.ReadFrom(reader)
+			if buf.String() != tt.body {
+				t.Errorf("%s: body = %q, want %q", tt.name, buf.String(), tt.body)
+			}
+		}
+	}
+}
+
+func TestStreamScriptRewriteAcrossBuffers(t *testing.T) {
+	// URL跨越读取缓冲区的边界
+	prefix := strings.Repeat("#", rewriteSniffBytes-20)
+	script := prefix + " curl -fsSL https://raw.githubusercontent.com/o/r/main/install.sh | sh\n" +
+		"wget https://github.com/o/r/releases/download/v1/tool.tar.gz.\n" +
+		"echo https://example.com/a\n" +
+		"no trailing newline https://gitlab.com/g/p/-/raw/main/a.sh"
+	want := prefix + " curl -fsSL http://proxy.local/https://raw.githubusercontent.com/o/r/main/install.sh | sh\n" +
+		"wget http://proxy.local/https://github.com/o/r/releases/download/v1/tool.tar.gz.\n" +
+		"echo https://example.com/a\n" +
+		"no trailing newline http://proxy.local/https://gitlab.com/g/p/-/raw/main/a.sh"
+
+	var out bytes.Buffer
+	src := bufio.NewReaderSize(strings.NewReader(script), rewriteSniffBytes)
+	if err := streamScriptRewrite(&out, src, "http://proxy.local"); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != want {
+		t.Errorf("streamScriptRewrite:\n got  %q\n want %q", out.String()[len(prefix):], want[len(prefix):])
+	}
+}
+
+func TestProxyScriptRewrite(t *testing.T) {
+	var upstreamQuery, upstreamEncoding string
+	stubUpstream(t, func(r *http.Request) (*http.Response, error) {
+		upstreamQuery, upstreamEncoding = r.URL.RawQuery, r.Header.Get("Accept-Encoding")
+		header := http.Header{"Content-Type": {"text/plain; charset=utf-8"}, "Content-Length": {"46"}, "Etag": {`"abc"`}}
+		return stubResponse(r, http.StatusOK, header, "curl https://github.com/o/r/raw/main/b.sh | sh\n"), nil
+	})
+
+	for _, path := range []string{
+		"/https://raw.githubusercontent.com/o/r/main/install.sh?token=a%2Bb&rewrite=1",
+		"/rewrite/https://raw.githubusercontent.com/o/r/main/install.sh?token=a%2Bb",
+	} {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Host = "proxy.local"
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		proxyHandler(w, r)
+
+		if upstreamQuery != "token=a%2Bb" || upstreamEncoding == "gzip" {
+			t.Errorf("%s: upstream query %q, Accept-Encoding %q", path, upstreamQuery, upstreamEncoding)
+		}
+		if want := "curl http://proxy.local/https://github.com/o/r/raw/main/b.sh | sh\n"; w.Body.String() != want {
+			t.Errorf("%s: body = %q, want %q", path, w.Body.String(), want)
+		}
+		if w.Header().Get("Content-Length") != "" || w.Header().Get("ETag") != "" {
+			t.Errorf("%s: stale headers %v", path, w.Header())
+		}
+	}
+}
+
+func TestRewriteTextURLs(t *testing.T) {
+	const base = "http://proxy.local"
+	tests := []struct {
+		text    string
+		want    string
+		changed []string
+		skipped map[string]string
+	}{
+		{
+			text:    "see https://github.com/o/r/blob/main/a.txt.",
+			want:    "see http://proxy.local/https://github.com/o/r/blob/main/a.txt.",
+			changed: []string{"https://github.com/o/r/blob/main/a.txt"},
+		},
+		{
+			// 末尾的标点和括号不属于URL
+			text:    "(https://raw.githubusercontent.com/o/r/main/a.sh), https://gitlab.com/g/p/-/raw/main/b.sh;",
+			want:    "(http://proxy.local/https://raw.githubusercontent.com/o/r/main/a.sh), http://proxy.local/https://gitlab.com/g/p/-/raw/main/b.sh;",
+			changed: []string{"https://raw.githubusercontent.com/o/r/main/a.sh", "https://gitlab.com/g/p/-/raw/main/b.sh"},
+		},
+		{
+			text:    "[x](https://huggingface.co/org/m/resolve/main/c.json?download=true)!",
+			want:    "[x](http://proxy.local/https://huggingface.co/org/m/resolve/main/c.json?download=true)!",
+			changed: []string{"https://huggingface.co/org/m/resolve/main/c.json?download=true"},
+		},
+		{
+			text: "already http://proxy.local/https://github.com/o/r and http://github.com/o/r and https://example.com/x",
+			want: "already http://proxy.local/https://github.com/o/r and http://github.com/o/r and https://example.com/x",
+			skipped: map[string]string{
+				"http://proxy.local/https://github.com/o/r": "已经是代理链接",
+				"http://github.com/o/r":                     "",
+				"https://example.com/x":                     "不支持的域名",
+			},
+		},
+		{text: "no urls here", want: "no urls here"},
+	}
+	for _, tt := range tests {
+		var changed []string
+		skipped := make(map[string]string)
+		got := rewriteTextURLs([]byte(tt.text), base, func(rw URLRewrite, ok bool) {
+			if ok {
+				changed = append(changed, rw.Original)
+				if rw.Proxied != base+"/"+rw.Original || rw.Target == "" {
+					t.Errorf("%s: proxied %q, target %q", rw.Original, rw.Proxied, rw.Target)
+				}
+			} else {
+				skipped[rw.Original] = rw.Reason
+				if rw.Reason == "" {
+					t.Errorf("%s: skipped without reason", rw.Original)
+				}
+			}
+		})
+		if string(got) != tt.want {
+			t.Errorf("rewriteTextURLs(%q)\n got  %q\n want %q", tt.text, got, tt.want)
+		}
+		if strings.Join(changed, " ") != strings.Join(tt.changed, " ") {
+			t.Errorf("%q: changed %v, want %v", tt.text, changed, tt.changed)
+		}
+		if len(skipped) != len(tt.skipped) {
+			t.Errorf("%q: skipped %v, want %v", tt.text, skipped, tt.skipped)
+		}
+		for original, reason := range tt.skipped {
+			if got, ok := skipped[original]; !ok || reason != "" && got != reason {
+				t.Errorf("%q: skipped[%s] = %q, want %q", tt.text, original, got, reason)
+			}
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+// 客户端配置脚本的模板数据
+type setupScriptData struct {
+	BaseURL string
+	// 经过代理的 Hugging Face Hub 地址，未启用 Hugging Face 时为空
+	HFEndpoint string
+	Rules      []GitInsteadOfRule
+}
+
+var setupTemplateFuncs = template.FuncMap{
+	"sq":  shellQuote,
+	"psq": powershellQuote,
+	"insteadOfKey": func(rule GitInsteadOfRule) string {
+		return "url." + rule.ProxyPrefix + ".insteadOf"
+	},
+	"pushInsteadOfKey": func(rule GitInsteadOfRule) string {
+		return "url." + rule.InsteadOf + ".pushInsteadOf"
+	},
+}
+
+// POSIX shell 单引号转义
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// PowerShell 单引号转义，弯单引号（‘ ’ ‚ ‛）同样作为单引号处理
+func powershellQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range s {
+		switch r {
+		case '\'', '\u2018', '\u2019', '\u201a', '\u201b':
+			b.WriteRune(r)
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+var setupShTemplate = template.Must(template.New("setup.sh").Funcs(setupTemplateFuncs).Parse(`#!/bin/sh
+# Git文件加速代理 客户端配置脚本，由 {{.BaseURL}} 生成
+# 用法: curl -fsSL {{.BaseURL}}/setup.sh | sh
+set -e
+
+if command -v git >/dev/null 2>&1; then
+	echo "配置git加速..."
+{{- range .Rules}}
+	git config --global {{sq (insteadOfKey .)}} {{sq .InsteadOf}}
+	git config --global {{sq (pushInsteadOfKey .)}} {{sq .InsteadOf}}
+{{- end}}
+else
+	echo "未找到git，跳过git配置"
+fi
+
+echo "写入 $HOME/.ghproxy.sh ..."
+cat > "$HOME/.ghproxy.sh" <<'GHPROXY_EOF'
+# Git文件加速代理配置，由 setup.sh 生成
+export GHPROXY_URL={{sq .BaseURL}}
+{{- if .HFEndpoint}}
+export HF_ENDPOINT={{sq .HFEndpoint}}
+{{- end}}
+
+# 生成加速链接: ghproxy_url <url>
+ghproxy_url() {
+	printf '%s/%s\n' "$GHPROXY_URL" "$1"
+}
+
+# 通过代理下载文件: ghget <url> [输出文件]
+ghget() {
+	if [ -z "$1" ]; then
+		echo "用法: ghget <url> [输出文件]" >&2
+		return 1
+	fi
+	_ghget_out="${2:-$(basename "${1%%\?*}")}"
+	if command -v curl >/dev/null 2>&1; then
+		curl -fL -o "$_ghget_out" "$GHPROXY_URL/$1"
+	else
+		wget -O "$_ghget_out" "$GHPROXY_URL/$1"
+	fi
+}
+GHPROXY_EOF
+
+installed=""
+for rc in "$HOME/.bashrc" "$HOME/.zshrc" "$HOME/.profile"; do
+	[ -f "$rc" ] || continue
+	if ! grep -q '.ghproxy.sh' "$rc"; then
+		printf '\n[ -f "$HOME/.ghproxy.sh" ] && . "$HOME/.ghproxy.sh"\n' >> "$rc"
+	fi
+	installed="$installed $rc"
+done
+
+if [ -n "$installed" ]; then
+	echo "已在以下文件中加载 ~/.ghproxy.sh:$installed"
+	echo "完成，重新打开终端或执行 . ~/.ghproxy.sh 后生效"
+else
+	echo "未找到shell配置文件，请手动在配置文件中加入: . ~/.ghproxy.sh"
+fi
+`))
+
+var setupPs1Template = template.Must(template.New("setup.ps1").Funcs(setupTemplateFuncs).Parse(`# Git文件加速代理 客户端配置脚本，由 {{.BaseURL}} 生成
+# 用法: irm {{.BaseURL}}/setup.ps1 | iex
+$ErrorActionPreference = 'Stop'
+
+if (Get-Command git -ErrorAction SilentlyContinue) {
+    Write-Host '配置git加速...'
+{{- range .Rules}}
+    git config --global {{psq (insteadOfKey .)}} {{psq .InsteadOf}}
+    git config --global {{psq (pushInsteadOfKey .)}} {{psq .InsteadOf}}
+{{- end}}
+} else {
+    Write-Host '未找到git，跳过git配置'
+}
+
+[Environment]::SetEnvironmentVariable('GHPROXY_URL', {{psq .BaseURL}}, 'User')
+$env:GHPROXY_URL = {{psq .BaseURL}}
+{{- if .HFEndpoint}}
+[Environment]::SetEnvironmentVariable('HF_ENDPOINT', {{psq .HFEndpoint}}, 'User')
+$env:HF_ENDPOINT = {{psq .HFEndpoint}}
+{{- end}}
+
+$ghproxyScript = Join-Path $HOME '.ghproxy.ps1'
+Write-Host "写入 $ghproxyScript ..."
+Set-Content -Path $ghproxyScript -Encoding UTF8 -Value @'
+# Git文件加速代理配置，由 setup.ps1 生成
+
+# 生成加速链接: ghproxy_url <url>
+function ghproxy_url([string]$Url) {
+    "$env:GHPROXY_URL/$Url"
+}
+
+# 通过代理下载文件: ghget <url> [输出文件]
+function ghget([string]$Url, [string]$OutFile) {
+    if (-not $Url) {
+        Write-Error '用法: ghget <url> [输出文件]'
+        return
+    }
+    if (-not $OutFile) {
+        $OutFile = [IO.Path]::GetFileName(($Url -split '\?')[0])
+    }
+    Invoke-WebRequest -Uri "$env:GHPROXY_URL/$Url" -OutFile $OutFile
+}
+'@
+
+if (-not (Test-Path $PROFILE)) {
+    New-Item -ItemType File -Path $PROFILE -Force | Out-Null
+}
+if (-not (Select-String -Path $PROFILE -Pattern '.ghproxy.ps1' -SimpleMatch -Quiet)) {
+    Add-Content -Path $PROFILE -Value ". '$ghproxyScript'"
+}
+
+Write-Host '完成，重新打开终端后生效'
+`))
+
+// 客户端配置脚本：GET /setup.sh、/setup.ps1
+// 把git insteadOf规则、HF_ENDPOINT和辅助函数持久化到开发机，代理地址取自请求的Host和协议
+func setupScriptHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "只支持GET请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	baseURL := requestBaseURL(r)
+	rules, err := gitInsteadOfRules(baseURL, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data := setupScriptData{BaseURL: baseURL, Rules: rules}
+	if config.platformEnabled(platformHuggingFace) {
+		data.HFEndpoint = baseURL + "/https://huggingface.co"
+	}
+
+	tmpl := setupShTemplate
+	if r.URL.Path == "/setup.ps1" {
+		tmpl = setupPs1Template
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, "生成脚本失败", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://gh.example.com", `'https://gh.example.com'`},
+		{"it's", `'it'\''s'`},
+		{"$(id)", `'$(id)'`},
+		{"`id`", "'`id`'"},
+		{"", `''`},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		return
+	}
+	// 交给shell解析，确认得到的是原文而不是命令替换的结果
+	for _, s := range []string{"a'b", "$(echo pwned)", "`echo pwned`", `"$HOME"\`} {
+		out, err := exec.Command("sh", "-c", "printf %s "+shellQuote(s)).Output()
+		if err != nil {
+			t.Fatalf("sh: %v", err)
+		}
+		if string(out) != s {
+			t.Errorf("sh解析 %s = %q, want %q", shellQuote(s), out, s)
+		}
+	}
+}
+
+func TestPowershellQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://gh.example.com", `'https://gh.example.com'`},
+		{"it's", `'it''s'`},
+		{"$env:PATH", `'$env:PATH'`},
+		{"`n", "'`n'"},
+		{"a’b", "'a’’b'"},
+		{"‘‚‛", "'‘‘‚‚‛‛'"},
+	}
+	for _, tt := range tests {
+		if got := powershellQuote(tt.in); got != tt.want {
+			t.Errorf("powershellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func setupScript(t *testing.T, path, host string) string {
+	t.Helper()
+	r := httptest.NewRequest("GET", path, nil)
+	r.Host = host
+	w := httptest.NewRecorder()
+	setupScriptHandler(w, r)
+	if w.Code != 200 {
+		t.Fatalf("%s status = %d, body = %s", path, w.Code, w.Body.String())
+	}
+	return w.Body.String()
+}
+
+func TestSetupScriptQuotesBaseURL(t *testing.T) {
+	host := "x$(id)'`y`"
+
+	sh := setupScript(t, "/setup.sh", host)
+	for _, want := range []string{
+		`export GHPROXY_URL='http://x$(id)'\''` + "`y`'",
+		`export HF_ENDPOINT='http://x$(id)'\''` + "`y`/https://huggingface.co'",
+		`git config --global 'url.http://x$(id)'\''` + "`y`/https://github.com/.insteadOf' 'https://github.com/'",
+	} {
+		if !strings.Contains(sh, want) {
+			t.Errorf("setup.sh 缺少 %s", want)
+		}
+	}
+	if _, err := exec.LookPath("sh"); err == nil {
+		if out, err := exec.Command("sh", "-n", "-c", sh).CombinedOutput(); err != nil {
+			t.Errorf("setup.sh 语法错误: %v\n%s", err, out)
+		}
+	}
+
+	ps1 := setupScript(t, "/setup.ps1", host)
+	for _, want := range []string{
+		"$env:GHPROXY_URL = 'http://x$(id)''`y`'",
+		"[Environment]::SetEnvironmentVariable('GHPROXY_URL', 'http://x$(id)''`y`', 'User')",
+		"$env:HF_ENDPOINT = 'http://x$(id)''`y`/https://huggingface.co'",
+		"git config --global 'url.http://x$(id)''`y`/https://github.com/.insteadOf' 'https://github.com/'",
+	} {
+		if !strings.Contains(ps1, want) {
+			t.Errorf("setup.ps1 缺少 %s", want)
+		}
+	}
+}
+
+func TestSetupScriptWithoutHuggingFace(t *testing.T) {
+	withConfig(t, func(cfg *Config) {
+		cfg.Platforms = []string{platformGitHub}
+	})
+	sh := setupScript(t, "/setup.sh", "gh.example.com")
+	if strings.Contains(sh, "HF_ENDPOINT") || strings.Contains(sh, "gitlab.com") {
+		t.Errorf("未启用的平台不应出现在脚本中:\n%s", sh)
+	}
+	if !strings.Contains(sh, "export GHPROXY_URL='http://gh.example.com'") {
+		t.Errorf("setup.sh 缺少 GHPROXY_URL:\n%s", sh)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// 短路径前缀（类似jsDelivr）：/gh/owner/repo@ref/path、/gl/group/project@ref/path、/hf/org/model@rev/path
+var shortPathPlatforms = map[string]string{
+	"gh": platformGitHub,
+	"gl": platformGitLab,
+	"hf": platformHuggingFace,
+}
+
+// 省略ref时使用的默认值
+const (
+	defaultGitHubShortRef      = "HEAD"
+	defaultHuggingFaceShortRev = "main"
+)
+
+// 把省略协议的路径（github.com/...）和短路径（gh/...）展开为完整URL，其他路径原样返回。
+// 路径和查询参数的转义保持原样，展开后的URL与完整URL走同样的转换和校验流程
+func expandShortPath(requestPath string) string {
+	pathPart, query, hasQuery := strings.Cut(requestPath, "?")
+	first, rest, _ := strings.Cut(pathPart, "/")
+
+	var expanded string
+	if platform, ok := shortPathPlatforms[first]; ok {
+		if rest != "" && config.platformEnabled(platform) {
+			expanded = expandShortRepoPath(platform, rest)
+		}
+	} else if isSupportedDomain(normalizeHost(first)) {
+		expanded = "https://" + pathPart
+	}
+
+	if expanded == "" {
+		return requestPath
+	}
+	if hasQuery {
+		expanded += "?" + query
+	}
+	return expanded
+}
+
+// 展开短路径，仓库名所在的路径段可以带 @ref；无法展开时返回空字符串
+func expandShortRepoPath(platform, rest string) string {
+	segments := strings.Split(rest, "/")
+	at := -1
+	for i, seg := range segments {
+		if strings.Contains(seg, "@") {
+			at = i
+			break
+		}
+	}
+	// git smart HTTP 请求（git clone 短路径）直接对应仓库地址
+	gitPath := at < 0 && isGitSmartHTTP("/"+rest)
+
+	switch platform {
+	case platformGitHub:
+		if gitPath {
+			return "https://github.com/" + rest
+		}
+		// owner/repo[@ref]/path
+		if len(segments) < 2 || (at >= 0 && at != 1) {
+			return ""
+		}
+		repo, ref, _ := strings.Cut(segments[1], "@")
+		if ref == "" {
+			ref = defaultGitHubShortRef
+		}
+		if len(segments) == 2 {
+			return "https://github.com/" + segments[0] + "/" + repo
+		}
+		return "https://raw.githubusercontent.com/" + segments[0] + "/" + repo + "/" + ref + "/" + strings.Join(segments[2:], "/")
+
+	case platformGitLab:
+		// 子组的层级不固定，没有 @ref 时整个路径都是仓库
+		if at < 0 {
+			return "https://gitlab.com/" + rest
+		}
+		if at == 0 {
+			return ""
+		}
+		repo, ref, _ := strings.Cut(segments[at], "@")
+		if ref == "" {
+			return ""
+		}
+		repoPath := strings.Join(append(segments[:at:at], repo), "/")
+		if at == len(segments)-1 {
+			return "https://gitlab.com/" + repoPath + "/-/tree/" + ref
+		}
+		return "https://gitlab.com/" + repoPath + "/-/raw/" + ref + "/" + strings.Join(segments[at+1:], "/")
+
+	case platformHuggingFace:
+		if gitPath {
+			return "https://huggingface.co/" + rest
+		}
+		prefix := ""
+		if segments[0] == "datasets" || segments[0] == "spaces" {
+			prefix = segments[0] + "/"
+			segments = segments[1:]
+			if at >= 0 {
+				at--
+			}
+		}
+		// org/name[@rev]/path，不带组织名的旧模型必须写 @rev
+		repoEnd := at
+		if repoEnd < 0 {
+			repoEnd = 1
+		}
+		if repoEnd > 1 || len(segments) <= repoEnd {
+			return ""
+		}
+		repo, rev, _ := strings.Cut(segments[repoEnd], "@")
+		if rev == "" {
+			rev = defaultHuggingFaceShortRev
+		}
+		repoPath := prefix + strings.Join(append(segments[:repoEnd:repoEnd], repo), "/")
+		if len(segments) == repoEnd+1 {
+			return "https://huggingface.co/" + repoPath
+		}
+		return "https://huggingface.co/" + repoPath + "/resolve/" + rev + "/" + strings.Join(segments[repoEnd+1:], "/")
+	}
+	return ""
+}
+
+// 最短形式的加速链接：文件链接使用短路径，其他链接省略 https://
+func shortLink(baseURL, originalURL string, src *SourceURL) string {
+	if src != nil && src.Kind == SourceFile {
+		if path := shortFilePath(src); path != "" {
+			return baseURL + "/" + path
+		}
+	}
+	if rest, ok := strings.CutPrefix(originalURL, "https://"); ok {
+		return baseURL + "/" + rest
+	}
+	return baseURL + "/" + originalURL
+}
+
+// 文件的短路径；ref中带 / 时无法表示为短路径，返回空字符串
+func shortFilePath(src *SourceURL) string {
+	if src.Ref == "" || strings.ContainsAny(src.Ref, "/@") {
+		return ""
+	}
+	ref := url.PathEscape(src.Ref)
+	var repo string
+	switch src.Platform {
+	case platformGitHub:
+		repo = "gh/" + escapePathSegments(src.RepoID())
+		if src.Ref != defaultGitHubShortRef {
+			repo += "@" + ref
+		}
+	case platformGitLab:
+		repo = "gl/" + escapePathSegments(src.RepoID()) + "@" + ref
+	case platformHuggingFace:
+		repo = "hf/" + escapePathSegments(src.RepoPath())
+		// 不带组织名的旧模型必须写 @rev 才能区分仓库和文件路径
+		if src.Ref != defaultHuggingFaceShortRev || src.Namespace == "" {
+			repo += "@" + ref
+		}
+	default:
+		return ""
+	}
+
+	path := repo + "/" + escapePathSegments(src.FilePath)
+	if src.URL != nil && src.URL.RawQuery != "" {
+		path += "?" + src.URL.RawQuery
+	}
+	return path
+}
@@ -0,0 +1,110 @@
+package main
+
+import "testing"
+
+func TestExpandShortPath(t *testing.T) {
+	tests := []struct {
+		name, path, want string
+	}{
+		{"gh默认ref", "gh/owner/repo/src/app.go", "https://raw.githubusercontent.com/owner/repo/HEAD/src/app.go"},
+		{"gh带ref", "gh/owner/repo@v1.0/src/app.go", "https://raw.githubusercontent.com/owner/repo/v1.0/src/app.go"},
+		{"gh保留查询参数", "gh/owner/repo/a.txt?x=1", "https://raw.githubusercontent.com/owner/repo/HEAD/a.txt?x=1"},
+		{"gh仓库", "gh/owner/repo", "https://github.com/owner/repo"},
+		{"gh git clone", "gh/owner/repo.git/info/refs?service=git-upload-pack", "https://github.com/owner/repo.git/info/refs?service=git-upload-pack"},
+		{"gh不完整", "gh/owner", "gh/owner"},
+		{"gh只有前缀", "gh", "gh"},
+		{"gh的@不在仓库段", "gh/owner@x/repo/a.txt", "gh/owner@x/repo/a.txt"},
+
+		{"gl子组文件", "gl/group/sub/project@main/docs/a.md", "https://gitlab.com/group/sub/project/-/raw/main/docs/a.md"},
+		{"gl目录", "gl/group/project@v1", "https://gitlab.com/group/project/-/tree/v1"},
+		{"gl子组仓库", "gl/group/sub/project", "https://gitlab.com/group/sub/project"},
+		{"gl空ref", "gl/group/project@/a.md", "gl/group/project@/a.md"},
+		{"gl的@在第一段", "gl/group@main/a.md", "gl/group@main/a.md"},
+
+		{"hf默认rev", "hf/org/model/config.json", "https://huggingface.co/org/model/resolve/main/config.json"},
+		{"hf带rev", "hf/org/model@v2/sub/w.bin", "https://huggingface.co/org/model/resolve/v2/sub/w.bin"},
+		{"hf仓库", "hf/org/model", "https://huggingface.co/org/model"},
+		{"hf数据集", "hf/datasets/org/ds/data/train.csv", "https://huggingface.co/datasets/org/ds/resolve/main/data/train.csv"},
+		{"hf数据集带rev", "hf/datasets/org/ds@refs%2Fconvert%2Fparquet/a.parquet", "https://huggingface.co/datasets/org/ds/resolve/refs%2Fconvert%2Fparquet/a.parquet"},
+		{"hf空间", "hf/spaces/org/app", "https://huggingface.co/spaces/org/app"},
+		{"hf无组织名模型带rev", "hf/gpt2@main/config.json", "https://huggingface.co/gpt2/resolve/main/config.json"},
+		// 不写 @rev 时第一段总是组织名，hf/<model>/<file> 被当作仓库 <org>/<model>
+		{"hf无组织名模型省略rev", "hf/gpt2/config.json", "https://huggingface.co/gpt2/config.json"},
+		{"hf不完整", "hf/org", "hf/org"},
+		{"hf数据集不完整", "hf/datasets/org", "hf/datasets/org"},
+		{"hf git clone", "hf/org/model.git/info/refs?service=git-upload-pack", "https://huggingface.co/org/model.git/info/refs?service=git-upload-pack"},
+
+		{"省略协议", "github.com/owner/repo/blob/main/a.go", "https://github.com/owner/repo/blob/main/a.go"},
+		{"完整URL原样返回", "https://github.com/owner/repo", "https://github.com/owner/repo"},
+		{"不支持的域名", "example.com/a", "example.com/a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandShortPath(tt.path); got != tt.want {
+				t.Errorf("expandShortPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandShortPathDisabledPlatform(t *testing.T) {
+	withConfig(t, func(cfg *Config) {
+		cfg.Platforms = []string{platformGitHub}
+	})
+	for _, path := range []string{"gl/group/project@main/a.md", "hf/org/model/config.json"} {
+		if got := expandShortPath(path); got != path {
+			t.Errorf("未启用的平台 expandShortPath(%q) = %q", path, got)
+		}
+	}
+}
+
+// 文件链接的短路径展开后应指向同一个文件
+func TestShortFilePathRoundTrip(t *testing.T) {
+	tests := []struct {
+		raw   string
+		short string
+	}{
+		{"https://github.com/owner/repo/blob/main/src/app.go", "gh/owner/repo@main/src/app.go"},
+		{"https://raw.githubusercontent.com/owner/repo/HEAD/a%20b.txt", "gh/owner/repo/a%20b.txt"},
+		{"https://github.com/owner/repo/raw/v1.0/dist/app.js?download=1", "gh/owner/repo@v1.0/dist/app.js?download=1"},
+		{"https://gitlab.com/group/sub/project/-/blob/main/docs/a.md", "gl/group/sub/project@main/docs/a.md"},
+		{"https://huggingface.co/org/model/resolve/main/config.json", "hf/org/model/config.json"},
+		{"https://huggingface.co/org/model/blob/v2/sub/w.bin", "hf/org/model@v2/sub/w.bin"},
+		{"https://huggingface.co/gpt2/resolve/main/config.json", "hf/gpt2@main/config.json"},
+		{"https://huggingface.co/datasets/org/ds/resolve/main/data/train.csv", "hf/datasets/org/ds/data/train.csv"},
+	}
+	for _, tt := range tests {
+		src, err := ParseSourceURL(tt.raw)
+		if err != nil {
+			t.Fatalf("ParseSourceURL(%q): %v", tt.raw, err)
+		}
+		short := shortFilePath(src)
+		if short != tt.short {
+			t.Errorf("shortFilePath(%q) = %q, want %q", tt.raw, short, tt.short)
+			continue
+		}
+		expanded, err := ParseSourceURL(expandShortPath(short))
+		if err != nil {
+			t.Fatalf("展开 %q: %v", short, err)
+		}
+		if expanded.RawURL() != src.RawURL() {
+			t.Errorf("%q 展开后 RawURL = %q, want %q", short, expanded.RawURL(), src.RawURL())
+		}
+	}
+}
+
+func TestShortFilePathUnrepresentable(t *testing.T) {
+	for _, raw := range []string{
+		// ref中带 / 或 @ 时无法写成短路径
+		"https://huggingface.co/datasets/org/ds/resolve/refs%2Fconvert%2Fparquet/a.parquet",
+		"https://github.com/owner/repo/raw/v1@x/a.txt",
+	} {
+		src, err := ParseSourceURL(raw)
+		if err != nil {
+			t.Fatalf("ParseSourceURL(%q): %v", raw, err)
+		}
+		if got := shortFilePath(src); got != "" {
+			t.Errorf("shortFilePath(%q) = %q, want 空", raw, got)
+		}
+	}
+}
@@ -0,0 +1,496 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// SourceKind 源链接类型
+type SourceKind string
+
+const (
+	SourceFile    SourceKind = "file"    // 单个文件（blob/raw/resolve）
+	SourceTree    SourceKind = "tree"    // 目录
+	SourceRepo    SourceKind = "repo"    // 仓库根路径或git协议路径
+	SourceRelease SourceKind = "release" // Release页面或附件
+	SourceArchive SourceKind = "archive" // 源码归档（zip/tar.gz）
+	SourceGist    SourceKind = "gist"    // Gist
+)
+
+// 平台名称
+const (
+	platformGitHub      = "github"
+	platformGitLab      = "gitlab"
+	platformHuggingFace = "huggingface"
+)
+
+// SourceURL 解析后的源链接
+type SourceURL struct {
+	Platform string `json:"platform"`
+	Host     string `json:"host"`
+	// 命名空间：GitHub为用户或组织，GitLab为组及子组（group/sub），Hugging Face为组织（可能为空）
+	Namespace string `json:"namespace"`
+	Repo      string `json:"repo"`
+	// Hugging Face仓库类型：model、dataset、space
+	RepoType string     `json:"repo_type,omitempty"`
+	Ref      string     `json:"ref,omitempty"`
+	FilePath string     `json:"file_path,omitempty"`
+	Kind     SourceKind `json:"kind"`
+	// ref是否已通过上游ref列表确认（带斜杠的分支名只有确认后才能正确拆分）
+	RefResolved bool `json:"ref_resolved,omitempty"`
+
+	// 原始链接
+	URL *url.URL `json:"-"`
+	// 操作类型（blob/raw/tree等）在路径段中的位置，没有时为-1
+	actionIndex int
+}
+
+// 无法识别的源链接
+var errUnknownSource = errors.New("无法识别的链接格式")
+
+// ParseSourceURL 解析GitHub、GitLab、Hugging Face的源链接
+func ParseSourceURL(raw string) (*SourceURL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, err
+	}
+	return parseSourceURL(u)
+}
+
+func parseSourceURL(u *url.URL) (*SourceURL, error) {
+	host := normalizeHost(u.Host)
+	segments := splitEscapedPath(u.EscapedPath())
+	src := &SourceURL{Host: host, URL: u, actionIndex: -1}
+
+	var ok bool
+	switch host {
+	case "github.com":
+		ok = src.parseGitHub(segments)
+	case "raw.githubusercontent.com":
+		ok = src.parseGitHubRaw(segments)
+	case "codeload.github.com":
+		ok = src.parseGitHubCodeload(segments)
+	case "gist.github.com", "gist.githubusercontent.com":
+		ok = src.parseGist(segments)
+	case "gitlab.com":
+		ok = src.parseGitLab(segments)
+	case "huggingface.co", "hf.co":
+		ok = src.parseHuggingFace(segments)
+	}
+	if !ok {
+		return nil, errUnknownSource
+	}
+	return src, nil
+}
+
+// 按 / 拆分转义形式的路径，每段单独解码，这样 %2F 会留在同一段内
+func splitEscapedPath(escaped string) []string {
+	segments := escapedSegments(escaped)
+	for i, seg := range segments {
+		if decoded, err := url.PathUnescape(seg); err == nil {
+			segments[i] = decoded
+		}
+	}
+	return segments
+}
+
+// 按 / 拆分转义形式的路径，保留每段的原始转义，忽略空段
+func escapedSegments(escaped string) []string {
+	var segments []string
+	for _, seg := range strings.Split(escaped, "/") {
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+	return segments
+}
+
+// 替换路径中的第i段（value为空表示删除该段），其他段保留原始转义
+func replacePathSegment(u *url.URL, i int, value string) {
+	segments := escapedSegments(u.EscapedPath())
+	if i < 0 || i >= len(segments) {
+		return
+	}
+	if value == "" {
+		segments = append(segments[:i], segments[i+1:]...)
+	} else {
+		segments[i] = value
+	}
+	escaped := "/" + strings.Join(segments, "/")
+	if strings.HasSuffix(u.EscapedPath(), "/") {
+		escaped += "/"
+	}
+	setEscapedPath(u, escaped)
+}
+
+// 把 ref 之后的路径段拆成 ref 和文件路径：refs/heads/x、refs/tags/x、refs/pr/x 占三段
+func splitRefPath(segments []string) (ref, filePath string) {
+	if len(segments) == 0 {
+		return "", ""
+	}
+	n := 1
+	if segments[0] == "refs" && len(segments) >= 3 {
+		n = 3
+	}
+	if n > len(segments) {
+		n = len(segments)
+	}
+	return strings.Join(segments[:n], "/"), strings.Join(segments[n:], "/")
+}
+
+func (s *SourceURL) parseGitHub(seg []string) bool {
+	s.Platform = platformGitHub
+	if len(seg) < 2 {
+		return false
+	}
+	s.Namespace, s.Repo = seg[0], strings.TrimSuffix(seg[1], ".git")
+	if len(seg) == 2 {
+		s.Kind = SourceRepo
+		return true
+	}
+
+	rest := seg[3:]
+	s.actionIndex = 2
+	switch seg[2] {
+	case "blob", "raw":
+		s.Kind = SourceFile
+		s.Ref, s.FilePath = splitRefPath(rest)
+		return s.Ref != "" && s.FilePath != ""
+	case "tree":
+		s.Kind = SourceTree
+		s.Ref, s.FilePath = splitRefPath(rest)
+		return s.Ref != ""
+	case "releases":
+		s.Kind = SourceRelease
+		// releases/download/<tag>/<asset>、releases/tag/<tag>、releases/latest
+		if len(rest) >= 3 && rest[0] == "download" {
+			s.Ref, s.FilePath = rest[1], strings.Join(rest[2:], "/")
+		} else if len(rest) >= 2 && rest[0] == "tag" {
+			s.Ref = strings.Join(rest[1:], "/")
+		}
+		return true
+	case "archive":
+		s.Kind = SourceArchive
+		s.Ref = archiveRef(strings.Join(rest, "/"))
+		return s.Ref != ""
+	case "tarball", "zipball":
+		s.Kind = SourceArchive
+		s.Ref = strings.Join(rest, "/")
+		return s.Ref != ""
+	case "info", "git-upload-pack":
+		s.Kind = SourceRepo
+		return true
+	}
+	return false
+}
+
+// raw.githubusercontent.com/<owner>/<repo>/<ref>/<path>
+func (s *SourceURL) parseGitHubRaw(seg []string) bool {
+	s.Platform = platformGitHub
+	if len(seg) < 4 {
+		return false
+	}
+	s.Namespace, s.Repo = seg[0], seg[1]
+	s.Kind = SourceFile
+	s.Ref, s.FilePath = splitRefPath(seg[2:])
+	return s.FilePath != ""
+}
+
+// codeload.github.com/<owner>/<repo>/<format>/<ref>
+func (s *SourceURL) parseGitHubCodeload(seg []string) bool {
+	s.Platform = platformGitHub
+	if len(seg) < 4 {
+		return false
+	}
+	s.Namespace, s.Repo = seg[0], seg[1]
+	s.Kind = SourceArchive
+	s.Ref = strings.Join(seg[3:], "/")
+	return true
+}
+
+// gist.github.com/<user>/<id>、gist.githubusercontent.com/<user>/<id>/raw/[<rev>/]<file>
+func (s *SourceURL) parseGist(seg []string) bool {
+	s.Platform = platformGitHub
+	if len(seg) < 2 {
+		return false
+	}
+	s.Namespace, s.Repo = seg[0], strings.TrimSuffix(seg[1], ".git")
+	s.Kind = SourceGist
+	if len(seg) >= 4 && seg[2] == "raw" {
+		rest := seg[3:]
+		if len(rest) >= 2 {
+			s.Ref = rest[0]
+			rest = rest[1:]
+		}
+		s.FilePath = strings.Join(rest, "/")
+	}
+	return true
+}
+
+// gitlab.com/<group>[/<subgroup>...]/<project>[/-/<action>/...]
+func (s *SourceURL) parseGitLab(seg []string) bool {
+	s.Platform = platformGitLab
+
+	// 项目路径在 "-" 之前；没有 "-" 时整段都是项目路径（可能带git协议后缀）
+	projectEnd, rest := len(seg), []string(nil)
+	for i, part := range seg {
+		if part == "-" {
+			projectEnd, rest = i, seg[i+1:]
+			break
+		}
+	}
+	project := seg[:projectEnd]
+	if rest == nil {
+		// git协议路径：<project>.git/info/refs、<project>.git/git-upload-pack
+		if n := len(project); n >= 2 && project[n-2] == "info" && project[n-1] == "refs" {
+			project = project[:n-2]
+		} else if n >= 1 && project[n-1] == "git-upload-pack" {
+			project = project[:n-1]
+		}
+	}
+	if len(project) < 2 {
+		return false
+	}
+	s.Namespace = strings.Join(project[:len(project)-1], "/")
+	s.Repo = strings.TrimSuffix(project[len(project)-1], ".git")
+
+	if rest == nil {
+		s.Kind = SourceRepo
+		return true
+	}
+	if len(rest) == 0 {
+		return false
+	}
+
+	s.actionIndex = projectEnd + 1
+	action, rest := rest[0], rest[1:]
+	switch action {
+	case "blob", "raw":
+		s.Kind = SourceFile
+		s.Ref, s.FilePath = splitRefPath(rest)
+		return s.Ref != "" && s.FilePath != ""
+	case "tree":
+		s.Kind = SourceTree
+		s.Ref, s.FilePath = splitRefPath(rest)
+		return s.Ref != ""
+	case "archive":
+		// -/archive/<ref>/<project>-<ref>.<ext>
+		s.Kind = SourceArchive
+		if len(rest) >= 2 {
+			s.Ref = strings.Join(rest[:len(rest)-1], "/")
+		} else if len(rest) == 1 {
+			s.Ref = archiveRef(rest[0])
+		}
+		return s.Ref != ""
+	case "releases":
+		// -/releases/<tag>、-/releases/<tag>/downloads/<asset>
+		s.Kind = SourceRelease
+		if len(rest) >= 1 {
+			s.Ref = rest[0]
+		}
+		if len(rest) >= 3 && rest[1] == "downloads" {
+			s.FilePath = strings.Join(rest[2:], "/")
+		}
+		return true
+	}
+	return false
+}
+
+// huggingface.co/[datasets|spaces/]<org>/<name>[/<resolve|blob|raw|tree>/<rev>/<path>]
+func (s *SourceURL) parseHuggingFace(seg []string) bool {
+	s.Platform = platformHuggingFace
+	s.RepoType = "model"
+	total := len(seg)
+	if len(seg) > 0 {
+		switch seg[0] {
+		case "datasets":
+			s.RepoType, seg = "dataset", seg[1:]
+		case "spaces":
+			s.RepoType, seg = "space", seg[1:]
+		case "api", "docs", "models", "papers", "collections":
+			return false
+		}
+	}
+	if len(seg) == 0 {
+		return false
+	}
+
+	// 没有组织名的旧式模型（如 gpt2）：第二段就是操作类型
+	if len(seg) == 1 || isHuggingFaceAction(seg[1]) {
+		s.Repo, seg = seg[0], seg[1:]
+	} else {
+		s.Namespace, s.Repo, seg = seg[0], seg[1], seg[2:]
+	}
+
+	if len(seg) == 0 {
+		s.Kind = SourceRepo
+		return true
+	}
+	s.actionIndex = total - len(seg)
+	action, rest := seg[0], seg[1:]
+	switch action {
+	case "resolve", "blob", "raw":
+		s.Kind = SourceFile
+		s.Ref, s.FilePath = splitRefPath(rest)
+		return s.Ref != "" && s.FilePath != ""
+	case "tree":
+		s.Kind = SourceTree
+		s.Ref, s.FilePath = splitRefPath(rest)
+		return s.Ref != ""
+	case "info", "git-upload-pack":
+		s.Kind = SourceRepo
+		return true
+	}
+	return false
+}
+
+func isHuggingFaceAction(seg string) bool {
+	switch seg {
+	case "resolve", "blob", "raw", "tree", "info", "git-upload-pack":
+		return true
+	}
+	return false
+}
+
+// 从归档文件名中取出ref：refs/heads/main.zip -> main，v1.0.tar.gz -> v1.0
+func archiveRef(name string) string {
+	for _, ext := range []string{".tar.gz", ".tar.bz2", ".tar", ".tgz", ".zip"} {
+		if strings.HasSuffix(name, ext) {
+			name = strings.TrimSuffix(name, ext)
+			break
+		}
+	}
+	name = strings.TrimPrefix(name, "refs/heads/")
+	name = strings.TrimPrefix(name, "refs/tags/")
+	return name
+}
+
+// 检查代理请求的链接类型，返回错误信息
+func validateProxySource(u *url.URL) string {
+	if platformOf(u.Host) == "" {
+		return ""
+	}
+	// Git LFS 的batch接口和对象下载
+	if isLFSBatchPath(u.Path) || isLFSObjectURL(u) {
+		return ""
+	}
+	src, err := parseSourceURL(u)
+	gitPath := err == nil && src.Kind == SourceRepo && isGitSmartHTTP(u.Path)
+
+	switch u.Host {
+	case "huggingface.co", "hf.co":
+		// Hugging Face 支持文件下载和Hub元数据API（HF_ENDPOINT镜像）
+		if isHubAPIPath(u.Path) {
+			return ""
+		}
+		if err != nil || (src.Kind != SourceFile && !gitPath) {
+			return "Hugging Face 链接需要包含具体文件路径（/resolve/ 或 /raw/）或Hub API路径（/api/models/ 等）"
+		}
+	case "github.com":
+		// 只允许文件、目录、Release、归档、gist和git拉取协议，不允许直接访问仓库根路径
+		if err != nil || (src.Kind == SourceRepo && !gitPath) {
+			return "GitHub 链接仅支持文件下载路径（/blob/, /raw/, /tree/）、Release、归档或gist，git clone请使用git命令"
+		}
+	case "gitlab.com":
+		if err != nil || (src.Kind == SourceRepo && !gitPath) {
+			return "GitLab 链接仅支持文件下载路径（/-/blob/, /-/raw/, /-/tree/）、Release或归档，git clone请使用git命令"
+		}
+	}
+	return ""
+}
+
+// 操作类型路径段，如 blob、raw、tree、resolve
+func (s *SourceURL) action() string {
+	segments := splitEscapedPath(s.URL.EscapedPath())
+	if s.actionIndex < 0 || s.actionIndex >= len(segments) {
+		return ""
+	}
+	return segments[s.actionIndex]
+}
+
+// RepoID 仓库标识，如 owner/repo、group/sub/project、org/name
+func (s *SourceURL) RepoID() string {
+	if s.Namespace == "" {
+		return s.Repo
+	}
+	return s.Namespace + "/" + s.Repo
+}
+
+// RepoPath 仓库路径，如 owner/repo、group/sub/project、datasets/org/name
+func (s *SourceURL) RepoPath() string {
+	path := s.RepoID()
+	switch s.RepoType {
+	case "dataset":
+		path = "datasets/" + path
+	case "space":
+		path = "spaces/" + path
+	}
+	return path
+}
+
+// RepoURL 仓库主页地址
+func (s *SourceURL) RepoURL() string {
+	host := s.Host
+	switch host {
+	case "raw.githubusercontent.com", "codeload.github.com":
+		host = "github.com"
+	case "gist.githubusercontent.com":
+		host = "gist.github.com"
+	case "hf.co":
+		host = "huggingface.co"
+	}
+	return "https://" + host + "/" + escapePathSegments(s.RepoPath())
+}
+
+// CloneURL git clone地址，不支持git clone的平台返回空字符串
+func (s *SourceURL) CloneURL() string {
+	switch s.Platform {
+	case platformGitHub, platformGitLab:
+		return s.RepoURL() + ".git"
+	case platformHuggingFace:
+		return s.RepoURL()
+	}
+	return ""
+}
+
+// RawURL 文件的直接下载地址，非文件链接返回空字符串
+func (s *SourceURL) RawURL() string {
+	if s.Kind != SourceFile {
+		return ""
+	}
+	ref, file := escapePathSegments(s.Ref), escapePathSegments(s.FilePath)
+	var raw string
+	switch s.Platform {
+	case platformGitHub:
+		raw = "https://raw.githubusercontent.com/" + escapePathSegments(s.RepoID()) + "/" + ref + "/" + file
+	case platformGitLab:
+		raw = s.RepoURL() + "/-/raw/" + ref + "/" + file
+	case platformHuggingFace:
+		raw = s.RepoURL() + "/resolve/" + ref + "/" + file
+	default:
+		return ""
+	}
+	if s.URL != nil && s.URL.RawQuery != "" {
+		raw += "?" + s.URL.RawQuery
+	}
+	return raw
+}
+
+// FileName 文件名（文件路径的最后一段）
+func (s *SourceURL) FileName() string {
+	name := s.FilePath
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// 逐段转义路径，保留 / 分隔符
+func escapePathSegments(path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return strings.Join(segments, "/")
+}
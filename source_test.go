@@ -0,0 +1,209 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSourceURL(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want SourceURL
+		// 文件链接的直接下载地址和仓库主页地址
+		rawURL  string
+		repoURL string
+	}{
+		{
+			raw:     "https://github.com/owner/repo",
+			want:    SourceURL{Platform: platformGitHub, Host: "github.com", Namespace: "owner", Repo: "repo", Kind: SourceRepo},
+			repoURL: "https://github.com/owner/repo",
+		},
+		{
+			raw:     "https://github.com/owner/repo.git/info/refs",
+			want:    SourceURL{Platform: platformGitHub, Host: "github.com", Namespace: "owner", Repo: "repo", Kind: SourceRepo},
+			repoURL: "https://github.com/owner/repo",
+		},
+		{
+			raw:     "https://github.com/owner/repo/blob/main/src/app.go",
+			want:    SourceURL{Platform: platformGitHub, Host: "github.com", Namespace: "owner", Repo: "repo", Ref: "main", FilePath: "src/app.go", Kind: SourceFile},
+			rawURL:  "https://raw.githubusercontent.com/owner/repo/main/src/app.go",
+			repoURL: "https://github.com/owner/repo",
+		},
+		{
+			// 带点的仓库名
+			raw:     "https://github.com/owner/socket.io/raw/v4.7.0/package.json",
+			want:    SourceURL{Platform: platformGitHub, Host: "github.com", Namespace: "owner", Repo: "socket.io", Ref: "v4.7.0", FilePath: "package.json", Kind: SourceFile},
+			rawURL:  "https://raw.githubusercontent.com/owner/socket.io/v4.7.0/package.json",
+			repoURL: "https://github.com/owner/socket.io",
+		},
+		{
+			raw:     "https://github.com/owner/repo.js.git",
+			want:    SourceURL{Platform: platformGitHub, Host: "github.com", Namespace: "owner", Repo: "repo.js", Kind: SourceRepo},
+			repoURL: "https://github.com/owner/repo.js",
+		},
+		{
+			// 带斜杠的ref未经解析时按第一段拆分
+			raw:     "https://github.com/owner/repo/blob/feature/login/src/app.go",
+			want:    SourceURL{Platform: platformGitHub, Host: "github.com", Namespace: "owner", Repo: "repo", Ref: "feature", FilePath: "login/src/app.go", Kind: SourceFile},
+			rawURL:  "https://raw.githubusercontent.com/owner/repo/feature/login/src/app.go",
+			repoURL: "https://github.com/owner/repo",
+		},
+		{
+			// 转义的斜杠留在ref中
+			raw:     "https://github.com/owner/repo/blob/feature%2Flogin/src/app.go",
+			want:    SourceURL{Platform: platformGitHub, Host: "github.com", Namespace: "owner", Repo: "repo", Ref: "feature/login", FilePath: "src/app.go", Kind: SourceFile},
+			rawURL:  "https://raw.githubusercontent.com/owner/repo/feature/login/src/app.go",
+			repoURL: "https://github.com/owner/repo",
+		},
+		{
+			raw:     "https://github.com/owner/repo/blob/refs/heads/main/README.md",
+			want:    SourceURL{Platform: platformGitHub, Host: "github.com", Namespace: "owner", Repo: "repo", Ref: "refs/heads/main", FilePath: "README.md", Kind: SourceFile},
+			rawURL:  "https://raw.githubusercontent.com/owner/repo/refs/heads/main/README.md",
+			repoURL: "https://github.com/owner/repo",
+		},
+		{
+			raw:     "https://github.com/owner/repo/tree/main/docs",
+			want:    SourceURL{Platform: platformGitHub, Host: "github.com", Namespace: "owner", Repo: "repo", Ref: "main", FilePath: "docs", Kind: SourceTree},
+			repoURL: "https://github.com/owner/repo",
+		},
+		{
+			raw:     "https://github.com/owner/repo/releases/download/v1.0/tool_linux_amd64.tar.gz",
+			want:    SourceURL{Platform: platformGitHub, Host: "github.com", Namespace: "owner", Repo: "repo", Ref: "v1.0", FilePath: "tool_linux_amd64.tar.gz", Kind: SourceRelease},
+			repoURL: "https://github.com/owner/repo",
+		},
+		{
+			raw:     "https://github.com/owner/repo/archive/refs/tags/v1.0.tar.gz",
+			want:    SourceURL{Platform: platformGitHub, Host: "github.com", Namespace: "owner", Repo: "repo", Ref: "v1.0", Kind: SourceArchive},
+			repoURL: "https://github.com/owner/repo",
+		},
+		{
+			raw:     "https://raw.githubusercontent.com/owner/repo/main/install.sh?token=x",
+			want:    SourceURL{Platform: platformGitHub, Host: "raw.githubusercontent.com", Namespace: "owner", Repo: "repo", Ref: "main", FilePath: "install.sh", Kind: SourceFile},
+			rawURL:  "https://raw.githubusercontent.com/owner/repo/main/install.sh?token=x",
+			repoURL: "https://github.com/owner/repo",
+		},
+		{
+			raw:     "https://codeload.github.com/owner/repo/tar.gz/refs/heads/main",
+			want:    SourceURL{Platform: platformGitHub, Host: "codeload.github.com", Namespace: "owner", Repo: "repo", Ref: "refs/heads/main", Kind: SourceArchive},
+			repoURL: "https://github.com/owner/repo",
+		},
+		{
+			raw:     "https://gist.githubusercontent.com/user/abc123/raw/def456/hello.sh",
+			want:    SourceURL{Platform: platformGitHub, Host: "gist.githubusercontent.com", Namespace: "user", Repo: "abc123", Ref: "def456", FilePath: "hello.sh", Kind: SourceGist},
+			repoURL: "https://gist.github.com/user/abc123",
+		},
+		{
+			raw:     "https://gitlab.com/group/project",
+			want:    SourceURL{Platform: platformGitLab, Host: "gitlab.com", Namespace: "group", Repo: "project", Kind: SourceRepo},
+			repoURL: "https://gitlab.com/group/project",
+		},
+		{
+			// GitLab子组
+			raw:     "https://gitlab.com/group/sub/deeper/project/-/blob/main/src/main.c",
+			want:    SourceURL{Platform: platformGitLab, Host: "gitlab.com", Namespace: "group/sub/deeper", Repo: "project", Ref: "main", FilePath: "src/main.c", Kind: SourceFile},
+			rawURL:  "https://gitlab.com/group/sub/deeper/project/-/raw/main/src/main.c",
+			repoURL: "https://gitlab.com/group/sub/deeper/project",
+		},
+		{
+			raw:     "https://gitlab.com/group/sub/my.project.git/info/refs",
+			want:    SourceURL{Platform: platformGitLab, Host: "gitlab.com", Namespace: "group/sub", Repo: "my.project", Kind: SourceRepo},
+			repoURL: "https://gitlab.com/group/sub/my.project",
+		},
+		{
+			raw:     "https://gitlab.com/group/sub/project/-/tree/release%2F2.x/docs",
+			want:    SourceURL{Platform: platformGitLab, Host: "gitlab.com", Namespace: "group/sub", Repo: "project", Ref: "release/2.x", FilePath: "docs", Kind: SourceTree},
+			repoURL: "https://gitlab.com/group/sub/project",
+		},
+		{
+			raw:     "https://gitlab.com/group/project/-/archive/v1.0/project-v1.0.tar.gz",
+			want:    SourceURL{Platform: platformGitLab, Host: "gitlab.com", Namespace: "group", Repo: "project", Ref: "v1.0", Kind: SourceArchive},
+			repoURL: "https://gitlab.com/group/project",
+		},
+		{
+			raw:     "https://gitlab.com/group/sub/project/-/releases/v2.0/downloads/tool.zip",
+			want:    SourceURL{Platform: platformGitLab, Host: "gitlab.com", Namespace: "group/sub", Repo: "project", Ref: "v2.0", FilePath: "tool.zip", Kind: SourceRelease},
+			repoURL: "https://gitlab.com/group/sub/project",
+		},
+		{
+			raw:     "https://huggingface.co/org/model/resolve/main/config.json",
+			want:    SourceURL{Platform: platformHuggingFace, Host: "huggingface.co", Namespace: "org", Repo: "model", RepoType: "model", Ref: "main", FilePath: "config.json", Kind: SourceFile},
+			rawURL:  "https://huggingface.co/org/model/resolve/main/config.json",
+			repoURL: "https://huggingface.co/org/model",
+		},
+		{
+			raw:     "https://hf.co/datasets/org/data.v2/blob/refs%2Fconvert%2Fparquet/train/0.parquet",
+			want:    SourceURL{Platform: platformHuggingFace, Host: "hf.co", Namespace: "org", Repo: "data.v2", RepoType: "dataset", Ref: "refs/convert/parquet", FilePath: "train/0.parquet", Kind: SourceFile},
+			rawURL:  "https://huggingface.co/datasets/org/data.v2/resolve/refs/convert/parquet/train/0.parquet",
+			repoURL: "https://huggingface.co/datasets/org/data.v2",
+		},
+		{
+			raw:     "https://huggingface.co/datasets/org/data/resolve/refs/pr/1/data.csv",
+			want:    SourceURL{Platform: platformHuggingFace, Host: "huggingface.co", Namespace: "org", Repo: "data", RepoType: "dataset", Ref: "refs/pr/1", FilePath: "data.csv", Kind: SourceFile},
+			rawURL:  "https://huggingface.co/datasets/org/data/resolve/refs/pr/1/data.csv",
+			repoURL: "https://huggingface.co/datasets/org/data",
+		},
+		{
+			// 没有组织名的旧式模型
+			raw:     "https://huggingface.co/gpt2/resolve/main/config.json",
+			want:    SourceURL{Platform: platformHuggingFace, Host: "huggingface.co", Repo: "gpt2", RepoType: "model", Ref: "main", FilePath: "config.json", Kind: SourceFile},
+			rawURL:  "https://huggingface.co/gpt2/resolve/main/config.json",
+			repoURL: "https://huggingface.co/gpt2",
+		},
+	}
+	for _, tt := range tests {
+		src, err := ParseSourceURL(tt.raw)
+		if err != nil {
+			t.Errorf("ParseSourceURL(%q): %v", tt.raw, err)
+			continue
+		}
+		got := *src
+		got.URL, got.actionIndex = nil, 0
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSourceURL(%q)\n got  %+v\n want %+v", tt.raw, got, tt.want)
+		}
+		if rawURL := src.RawURL(); rawURL != tt.rawURL {
+			t.Errorf("RawURL(%q) = %q, want %q", tt.raw, rawURL, tt.rawURL)
+		}
+		if repoURL := src.RepoURL(); repoURL != tt.repoURL {
+			t.Errorf("RepoURL(%q) = %q, want %q", tt.raw, repoURL, tt.repoURL)
+		}
+	}
+}
+
+func TestParseSourceURLUnknown(t *testing.T) {
+	for _, raw := range []string{
+		"https://github.com/owner",
+		"https://github.com/owner/repo/blob/main",
+		"https://github.com/owner/repo/pulls",
+		"https://gitlab.com/project",
+		"https://gitlab.com/group/project/-/issues/1",
+		"https://huggingface.co/api/models/org/model",
+		"https://example.com/owner/repo",
+	} {
+		if src, err := ParseSourceURL(raw); err == nil {
+			t.Errorf("ParseSourceURL(%q) = %+v, want error", raw, src)
+		}
+	}
+}
+
+func TestSplitRefPath(t *testing.T) {
+	tests := []struct {
+		segments []string
+		ref      string
+		filePath string
+	}{
+		{nil, "", ""},
+		{[]string{"main"}, "main", ""},
+		{[]string{"main", "src", "app.go"}, "main", "src/app.go"},
+		{[]string{"feature/login", "app.go"}, "feature/login", "app.go"},
+		{[]string{"refs", "heads", "main", "README.md"}, "refs/heads/main", "README.md"},
+		{[]string{"refs", "pr", "1"}, "refs/pr/1", ""},
+		{[]string{"refs", "heads"}, "refs", "heads"},
+	}
+	for _, tt := range tests {
+		ref, filePath := splitRefPath(tt.segments)
+		if ref != tt.ref || filePath != tt.filePath {
+			t.Errorf("splitRefPath(%q) = %q, %q, want %q, %q", tt.segments, ref, filePath, tt.ref, tt.filePath)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// 请求路径不是完整的http(s) URL
+var errInvalidTarget = errors.New("无效的URL格式，请使用完整的URL")
+
+// 从请求路径（RequestURI去掉开头的 /）中提取目标URL。
+// 路径和查询参数的转义保持原样（%2F、+ 等不会被解码），只修复被合并的 https:/ 协议分隔符
+func extractTargetURL(requestPath string) (*url.URL, error) {
+	// 整个URL被编码成一个参数的情况（如 https%3A%2F%2Fgithub.com%2F...），解码一次
+	if hasEncodedScheme(requestPath) {
+		decoded, err := url.PathUnescape(requestPath)
+		if err != nil {
+			return nil, errInvalidTarget
+		}
+		requestPath = decoded
+	}
+
+	requestPath = fixCollapsedScheme(requestPath)
+
+	lower := strings.ToLower(requestPath)
+	if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+		return nil, errInvalidTarget
+	}
+
+	u, err := url.Parse(requestPath)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, errInvalidTarget
+	}
+	// 主机名不区分大小写，统一为小写以便后续匹配平台
+	u.Host = strings.ToLower(u.Host)
+	// 片段不会发送给上游
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u, nil
+}
+
+// 是否以编码过的协议开头
+func hasEncodedScheme(s string) bool {
+	lower := strings.ToLower(s)
+	return strings.HasPrefix(lower, "https%3a%2f%2f") || strings.HasPrefix(lower, "http%3a%2f%2f")
+}
+
+// 修复被前置代理合并斜杠后的协议分隔符：https:/host/path -> https://host/path
+func fixCollapsedScheme(s string) string {
+	for _, scheme := range []string{"https", "http"} {
+		prefix := scheme + ":/"
+		if len(s) > len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) && s[len(prefix)] != '/' {
+			return scheme + "://" + s[len(prefix):]
+		}
+	}
+	return s
+}
+
+// 设置转义形式的路径，保留原有的转义（如 %2F）
+func setEscapedPath(u *url.URL, escaped string) {
+	path, err := url.PathUnescape(escaped)
+	if err != nil {
+		return
+	}
+	u.Path = path
+	u.RawPath = escaped
+}
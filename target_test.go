@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExtractTargetURL(t *testing.T) {
+	tests := []struct {
+		requestPath string
+		// 期望的目标URL，为空表示应返回 errInvalidTarget
+		want string
+	}{
+		{"https://github.com/o/r/raw/main/a.txt", "https://github.com/o/r/raw/main/a.txt"},
+		// + 和 %2F 在路径和查询参数中保持原样
+		{"https://github.com/o/r/raw/main/c++/a+b.txt?q=a+b", "https://github.com/o/r/raw/main/c++/a+b.txt?q=a+b"},
+		{"https://github.com/o/r/raw/feat%2Fx/a%2Fb.txt?path=a%2Fb", "https://github.com/o/r/raw/feat%2Fx/a%2Fb.txt?path=a%2Fb"},
+		{
+			"https://objects.githubusercontent.com/asset?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=AKIA%2F20240101%2Fus-east-1%2Fs3%2Faws4_request&X-Amz-Signature=ab%2Bcd%3D",
+			"https://objects.githubusercontent.com/asset?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=AKIA%2F20240101%2Fus-east-1%2Fs3%2Faws4_request&X-Amz-Signature=ab%2Bcd%3D",
+		},
+		// 前置代理合并了协议中的斜杠
+		{"https:/github.com/o/r/raw/main/a.txt", "https://github.com/o/r/raw/main/a.txt"},
+		{"HTTP:/github.com/o/r", "http://github.com/o/r"},
+		// 整个URL被编码
+		{"https%3A%2F%2Fgithub.com%2Fo%2Fr%2Fraw%2Fmain%2Fa.txt", "https://github.com/o/r/raw/main/a.txt"},
+		// 路径后面出现的 https:/ 不是协议分隔符，不修复
+		{"https://github.com/o/r/raw/main/https:/x.txt", "https://github.com/o/r/raw/main/https:/x.txt"},
+		{"https://GitHub.com/o/r#readme", "https://github.com/o/r"},
+		{"", ""},
+		{"?", ""},
+		{"?url=https://github.com", ""},
+		{"github.com/o/r", ""},
+		{"ftp://github.com/o/r", ""},
+		{"https:///o/r", ""},
+	}
+	for _, tt := range tests {
+		u, err := extractTargetURL(tt.requestPath)
+		if tt.want == "" {
+			if !errors.Is(err, errInvalidTarget) {
+				t.Errorf("extractTargetURL(%q) = %v, %v; want errInvalidTarget", tt.requestPath, u, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("extractTargetURL(%q): %v", tt.requestPath, err)
+		} else if got := u.String(); got != tt.want {
+			t.Errorf("extractTargetURL(%q) = %q, want %q", tt.requestPath, got, tt.want)
+		}
+	}
+}
+
+func TestFixCollapsedScheme(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"https:/github.com", "https://github.com"},
+		{"http:/github.com", "http://github.com"},
+		{"https://github.com", "https://github.com"},
+		{"https:/", "https:/"},
+		{"github.com/https:/x", "github.com/https:/x"},
+	}
+	for _, tt := range tests {
+		if got := fixCollapsedScheme(tt.in); got != tt.want {
+			t.Errorf("fixCollapsedScheme(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSetEscapedPath(t *testing.T) {
+	u, _ := extractTargetURL("https://github.com/o/r")
+	setEscapedPath(u, "/o/r/raw/feat%2Fx/a+b.txt")
+	if u.Path != "/o/r/raw/feat/x/a+b.txt" || u.EscapedPath() != "/o/r/raw/feat%2Fx/a+b.txt" {
+		t.Errorf("Path = %q, EscapedPath = %q", u.Path, u.EscapedPath())
+	}
+	// 无效的转义不修改路径
+	setEscapedPath(u, "/bad%zz")
+	if u.EscapedPath() != "/o/r/raw/feat%2Fx/a+b.txt" {
+		t.Errorf("invalid escape changed path to %q", u.EscapedPath())
+	}
+}
+
+// 代理请求上游时保持路径和查询参数的原始转义
+func TestProxyPassesTargetLosslessly(t *testing.T) {
+	var got string
+	stubUpstream(t, func(r *http.Request) (*http.Response, error) {
+		got = r.URL.String()
+		return stubResponse(r, http.StatusOK, nil, "ok"), nil
+	})
+	target := "https://raw.githubusercontent.com/o/r/feat%2Fx/c++/a%20b.txt?sig=ab%2Bcd%3D&x=a+b"
+	r := httptest.NewRequest("GET", "/"+target, nil)
+	proxyHandler(httptest.NewRecorder(), r)
+	if got != target {
+		t.Errorf("upstream URL = %q, want %q", got, target)
+	}
+
+	// 只有查询参数的请求返回首页
+	w := httptest.NewRecorder()
+	proxyHandler(w, httptest.NewRequest("GET", "/?", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("index: status %d, Content-Type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 上游API令牌池：每个平台可以配置多个令牌，每次请求选择剩余额度最多的令牌，
+// 额度耗尽或被限流的令牌在重置时间之前不再使用，全部不可用时匿名访问
+
+const (
+	// 被限流但上游没有给出重置时间时的暂停时长
+	defaultTokenBenchDuration = time.Minute
+	// 令牌无效（401）时的暂停时长
+	invalidTokenBenchDuration = 10 * time.Minute
+)
+
+// 令牌池中的一个令牌，状态字段由 tokenPool.mu 保护
+type upstreamToken struct {
+	platform string
+	value    string
+
+	// 上游返回的额度上限和剩余额度，-1表示未知
+	limit     int
+	remaining int
+	reset     time.Time
+	// 暂停使用的截止时间
+	benchedUntil time.Time
+
+	requests   int64
+	limited    int64
+	lastStatus int
+	lastUsed   time.Time
+}
+
+type tokenPool struct {
+	mu     sync.Mutex
+	tokens map[string][]*upstreamToken
+}
+
+var upstreamTokens = &tokenPool{tokens: make(map[string][]*upstreamToken)}
+
+// 按配置创建令牌池，github_api.token 与 tokens.github 合并，重复和空的令牌被忽略
+func newTokenPool(cfg *Config) *tokenPool {
+	pool := &tokenPool{tokens: make(map[string][]*upstreamToken)}
+	sources := map[string][]string{
+		platformGitHub:      append([]string{cfg.GitHubAPI.Token}, cfg.Tokens.GitHub...),
+		platformGitLab:      cfg.Tokens.GitLab,
+		platformHuggingFace: cfg.Tokens.HuggingFace,
+	}
+	for platform, values := range sources {
+		seen := make(map[string]bool)
+		for _, value := range values {
+			if value = strings.TrimSpace(value); value == "" || seen[value] {
+				continue
+			}
+			seen[value] = true
+			pool.tokens[platform] = append(pool.tokens[platform], &upstreamToken{
+				platform: platform, value: value, limit: -1, remaining: -1,
+			})
+		}
+	}
+	return pool
+}
+
+func setupTokenPool(cfg *Config) {
+	upstreamTokens = newTokenPool(cfg)
+	for _, platform := range []string{platformGitHub, platformGitLab, platformHuggingFace} {
+		if n := len(upstreamTokens.tokens[platform]); n > 0 {
+			log.Printf("%s 令牌池: %d 个令牌", platform, n)
+		}
+	}
+}
+
+// 使用令牌池的上游API：api.github.com、gitlab.com 和 Hugging Face 的 /api/ 接口
+func tokenPlatform(target *url.URL) string {
+	switch {
+	case target.Host == "api.github.com":
+		return platformGitHub
+	case target.Host == "gitlab.com" && strings.HasPrefix(target.Path, "/api/"):
+		return platformGitLab
+	case isHubHost(target.Host) && strings.HasPrefix(target.Path, "/api/"):
+		return platformHuggingFace
+	}
+	return ""
+}
+
+// 为发往上游API的请求设置令牌，返回使用的令牌；没有可用令牌时匿名访问，返回nil
+func (p *tokenPool) apply(h http.Header, target *url.URL) *upstreamToken {
+	platform := tokenPlatform(target)
+	if platform == "" {
+		return nil
+	}
+	t := p.acquire(platform, time.Now())
+	if t != nil {
+		h.Set("Authorization", "Bearer "+t.value)
+	}
+	return t
+}
+
+// 选择剩余额度最多的可用令牌
+func (p *tokenPool) acquire(platform string, now time.Time) *upstreamToken {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var best *upstreamToken
+	for _, t := range p.tokens[platform] {
+		if now.Before(t.benchedUntil) {
+			continue
+		}
+		// 已过重置时间，额度恢复
+		if !t.reset.IsZero() && !now.Before(t.reset) {
+			t.remaining = t.limit
+			t.reset = time.Time{}
+		}
+		if best == nil || t.healthier(best) {
+			best = t
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	best.requests++
+	best.lastUsed = now
+	// 上游响应返回前先扣减，并发请求分散到其他令牌
+	if best.remaining > 0 {
+		best.remaining--
+	}
+	return best
+}
+
+// 额度未知的令牌优先（尽快获取它的状态），其次是剩余额度多的，最后是使用次数少的
+func (t *upstreamToken) healthier(other *upstreamToken) bool {
+	if t.remaining != other.remaining {
+		if t.remaining < 0 || other.remaining < 0 {
+			return t.remaining < 0
+		}
+		return t.remaining > other.remaining
+	}
+	return t.requests < other.requests
+}
+
+// 根据上游响应更新令牌状态，额度耗尽或被限流时暂停使用
+func (p *tokenPool) record(t *upstreamToken, resp *http.Response) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	limit, remaining, reset, ok := parseRateLimit(resp.Header, now)
+	retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), now)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t.lastStatus = resp.StatusCode
+	if ok {
+		t.limit, t.remaining, t.reset = limit, remaining, reset
+	}
+
+	var until time.Time
+	switch {
+	case ok && remaining == 0:
+		until = reset
+	case resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == http.StatusForbidden && retryAfter > 0:
+		// GitHub的次级限流返回403和Retry-After
+		until = now.Add(retryAfter)
+	case resp.StatusCode == http.StatusUnauthorized && t.platform != platformHuggingFace:
+		// GitHub、GitLab 返回401说明令牌无效或已过期；Hugging Face 对不存在的仓库也返回401
+		t.benchedUntil = now.Add(invalidTokenBenchDuration)
+		log.Printf("%s 令牌 %s 无效，暂停使用 %s", t.platform, maskToken(t.value), invalidTokenBenchDuration)
+		return
+	default:
+		return
+	}
+	if !until.After(now) {
+		until = now.Add(defaultTokenBenchDuration)
+	}
+	t.limited++
+	t.benchedUntil = until
+	log.Printf("%s 令牌 %s 额度耗尽，暂停使用到 %s", t.platform, maskToken(t.value), until.Format(time.RFC3339))
+}
+
+// 解析速率限制响应头：GitHub使用 X-RateLimit-*，GitLab使用 RateLimit-*，重置时间为Unix时间戳；
+// Hugging Face 使用 RateLimit: "api";r=剩余额度;t=距重置的秒数，上限在 RateLimit-Policy 的 q 参数中
+func parseRateLimit(h http.Header, now time.Time) (limit, remaining int, reset time.Time, ok bool) {
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		value := h.Get(prefix + "Remaining")
+		if value == "" {
+			continue
+		}
+		if remaining, err := strconv.Atoi(value); err == nil {
+			limit, err := strconv.Atoi(h.Get(prefix + "Limit"))
+			if err != nil {
+				limit = -1
+			}
+			if epoch, err := strconv.ParseInt(h.Get(prefix+"Reset"), 10, 64); err == nil {
+				reset = time.Unix(epoch, 0)
+			}
+			return limit, remaining, reset, true
+		}
+	}
+
+	params := rateLimitParams(h.Get("RateLimit"))
+	remaining, err := strconv.Atoi(params["r"])
+	if err != nil {
+		return 0, 0, time.Time{}, false
+	}
+	if seconds, err := strconv.Atoi(params["t"]); err == nil {
+		reset = now.Add(time.Duration(seconds) * time.Second)
+	}
+	limit, err = strconv.Atoi(rateLimitParams(h.Get("RateLimit-Policy"))["q"])
+	if err != nil {
+		limit = -1
+	}
+	return limit, remaining, reset, true
+}
+
+// 解析 "名称";键=值;键=值 形式的响应头参数
+func rateLimitParams(value string) map[string]string {
+	params := make(map[string]string)
+	for _, part := range strings.Split(value, ";") {
+		if key, val, ok := strings.Cut(strings.TrimSpace(part), "="); ok {
+			params[key] = strings.Trim(val, `"`)
+		}
+	}
+	return params
+}
+
+// Retry-After：秒数或HTTP日期
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// 日志和管理接口只显示令牌的首尾几个字符
+func maskToken(value string) string {
+	if len(value) <= 12 {
+		return "****"
+	}
+	return value[:4] + "****" + value[len(value)-4:]
+}
+
+// TokenStatus 令牌池中单个令牌的使用情况
+type TokenStatus struct {
+	Platform string `json:"platform"`
+	// 掩码后的令牌
+	Token string `json:"token"`
+	// 当前是否参与轮换
+	Available bool `json:"available"`
+	// 上游返回的额度上限和剩余额度，-1表示还没有收到上游的速率限制信息
+	Limit     int    `json:"limit"`
+	Remaining int    `json:"remaining"`
+	Reset     string `json:"reset,omitempty"`
+	// 暂停使用的截止时间
+	BenchedUntil string `json:"benched_until,omitempty"`
+	// 使用次数，以及额度耗尽或被限流的次数
+	Requests    int64  `json:"requests"`
+	RateLimited int64  `json:"rate_limited"`
+	LastStatus  int    `json:"last_status,omitempty"`
+	LastUsed    string `json:"last_used,omitempty"`
+}
+
+// TokenPoolResponse 令牌池管理接口响应
+type TokenPoolResponse struct {
+	Success bool          `json:"success"`
+	Tokens  []TokenStatus `json:"tokens,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// 令牌池的当前状态，按平台和配置顺序排列
+func (p *tokenPool) status(now time.Time) []TokenStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	platforms := make([]string, 0, len(p.tokens))
+	for platform := range p.tokens {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	statuses := []TokenStatus{}
+	for _, platform := range platforms {
+		for _, t := range p.tokens[platform] {
+			status := TokenStatus{
+				Platform:    t.platform,
+				Token:       maskToken(t.value),
+				Available:   !now.Before(t.benchedUntil),
+				Limit:       t.limit,
+				Remaining:   t.remaining,
+				Reset:       formatTime(t.reset),
+				Requests:    t.requests,
+				RateLimited: t.limited,
+				LastStatus:  t.lastStatus,
+				LastUsed:    formatTime(t.lastUsed),
+			}
+			if !status.Available {
+				status.BenchedUntil = formatTime(t.benchedUntil)
+			}
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// 令牌池管理接口：GET /api/admin/tokens，需要 Authorization: Bearer <admin.token>，未配置 admin.token 时不开放
+func tokenPoolAdminAPI(w http.ResponseWriter, r *http.Request) {
+	if config.Admin.Token == "" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(config.Admin.Token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="ghproxy admin"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(TokenPoolResponse{Success: false, Error: "管理令牌无效"})
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(TokenPoolResponse{Success: false, Error: "只支持GET请求"})
+		return
+	}
+	json.NewEncoder(w).Encode(TokenPoolResponse{Success: true, Tokens: upstreamTokens.status(time.Now())})
+}
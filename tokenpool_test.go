@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name      string
+		header    map[string]string
+		limit     int
+		remaining int
+		reset     time.Time
+		ok        bool
+	}{
+		{
+			name:   "github",
+			header: map[string]string{"X-RateLimit-Limit": "5000", "X-RateLimit-Remaining": "4999", "X-RateLimit-Reset": "1700003600"},
+			limit:  5000, remaining: 4999, reset: time.Unix(1700003600, 0), ok: true,
+		},
+		{
+			name:   "gitlab",
+			header: map[string]string{"RateLimit-Limit": "2000", "RateLimit-Remaining": "0", "RateLimit-Reset": "1700000060"},
+			limit:  2000, remaining: 0, reset: time.Unix(1700000060, 0), ok: true,
+		},
+		{
+			name:   "github without limit",
+			header: map[string]string{"X-RateLimit-Remaining": "10"},
+			limit:  -1, remaining: 10, ok: true,
+		},
+		{
+			name:   "huggingface",
+			header: map[string]string{"RateLimit": `"api";r=489;t=189`, "RateLimit-Policy": `"fixed window";"api";q=500;w=300`},
+			limit:  500, remaining: 489, reset: now.Add(189 * time.Second), ok: true,
+		},
+		{
+			name:   "huggingface without policy",
+			header: map[string]string{"RateLimit": `"resolvers";r=0;t=30`},
+			limit:  -1, remaining: 0, reset: now.Add(30 * time.Second), ok: true,
+		},
+		{
+			name:   "invalid remaining",
+			header: map[string]string{"X-RateLimit-Remaining": "many"},
+		},
+		{
+			name: "none",
+		},
+	}
+	for _, tt := range tests {
+		h := make(http.Header)
+		for name, value := range tt.header {
+			h.Set(name, value)
+		}
+		limit, remaining, reset, ok := parseRateLimit(h, now)
+		if ok != tt.ok || ok && (limit != tt.limit || remaining != tt.remaining || !reset.Equal(tt.reset)) {
+			t.Errorf("%s: parseRateLimit = %d, %d, %v, %v, want %d, %d, %v, %v",
+				tt.name, limit, remaining, reset, ok, tt.limit, tt.remaining, tt.reset, tt.ok)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"Mon, 01 Jan 2024 00:00:30 GMT", 30 * time.Second},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestTokenPoolBenchesExhaustedToken(t *testing.T) {
+	pool := newTokenPool(&Config{Tokens: TokensConfig{GitHub: []string{"ghp_aaaaaaaaaaaaaaaa", "ghp_bbbbbbbbbbbbbbbb", "ghp_aaaaaaaaaaaaaaaa"}}})
+	if n := len(pool.tokens[platformGitHub]); n != 2 {
+		t.Fatalf("tokens = %d, want 2 (duplicates ignored)", n)
+	}
+
+	first := pool.acquire(platformGitHub, time.Now())
+	reset := time.Now().Add(time.Hour).Unix()
+	resp := &http.Response{StatusCode: http.StatusForbidden, Header: make(http.Header)}
+	resp.Header.Set("X-RateLimit-Limit", "5000")
+	resp.Header.Set("X-RateLimit-Remaining", "0")
+	resp.Header.Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+	pool.record(first, resp)
+
+	for i := 0; i < 3; i++ {
+		if got := pool.acquire(platformGitHub, time.Now()); got == first {
+			t.Fatalf("exhausted token %s acquired again", maskToken(first.value))
+		}
+	}
+	// 重置时间过后恢复使用
+	if got := pool.acquire(platformGitHub, time.Unix(reset+1, 0)); got == nil {
+		t.Error("no token available after reset")
+	}
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// 目录下载：GitHub、GitLab的目录链接（/tree/、/-/tree/）带 ?archive=zip|tar.gz 时打包为归档返回，不带时按普通链接代理。
+// 文件列表来自上游API，固定到同一个提交，逐个从raw地址下载后写入归档，边下载边返回给客户端
+
+const (
+	treeArchiveZip   = "zip"
+	treeArchiveTarGz = "tar.gz"
+)
+
+// git文件模式
+const (
+	gitModeExecutable = "100755"
+	gitModeSymlink    = "120000"
+)
+
+// 符号链接的目标路径长度上限
+const maxSymlinkTargetBytes = 4096
+
+// 目录的文件数或大小超过限制
+var errTreeTooLarge = errors.New("目录超过下载限制")
+
+// 目录中的一个文件
+type treeFile struct {
+	// 相对于目录的路径
+	path string
+	// git文件模式：100644、100755、120000
+	mode string
+	// 文件大小，-1表示未知（GitLab的目录API不返回大小）
+	size int64
+}
+
+// 是否是需要打包下载的目录链接：只有显式带 archive 参数的目录链接才打包
+func treeArchiveSource(target *url.URL) (*SourceURL, bool) {
+	if target.Host != "github.com" && target.Host != "gitlab.com" {
+		return nil, false
+	}
+	if !target.Query().Has("archive") {
+		return nil, false
+	}
+	src, err := parseSourceURL(target)
+	if err != nil || src.Kind != SourceTree {
+		return nil, false
+	}
+	return src, true
+}
+
+// 打包下载目录：?archive=zip 或 ?archive=tar.gz。HEAD请求只解析提交，不获取文件列表
+func serveTreeArchive(w http.ResponseWriter, r *http.Request, src *SourceURL) {
+	format := src.URL.Query().Get("archive")
+	if format != treeArchiveZip && format != treeArchiveTarGz {
+		http.Error(w, "不支持的归档格式（可选 zip、tar.gz）", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	if err := resolveSourceRef(ctx, src); err != nil {
+		http.Error(w, "解析ref失败: "+err.Error(), treeArchiveErrorStatus(err))
+		return
+	}
+	commit, err := resolveCommit(ctx, src)
+	if err != nil {
+		http.Error(w, "解析提交失败: "+err.Error(), treeArchiveErrorStatus(err))
+		return
+	}
+
+	// 归档内的顶层目录和文件名使用目录名，根目录时使用仓库名
+	name := path.Base(src.FilePath)
+	if src.FilePath == "" {
+		name = src.Repo
+	}
+	if r.Method == http.MethodHead {
+		setTreeArchiveHeaders(w.Header(), format, name, commit)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	files, err := listTreeFiles(ctx, src, commit)
+	if err != nil {
+		http.Error(w, "获取文件列表失败: "+err.Error(), treeArchiveErrorStatus(err))
+		return
+	}
+	if len(files) == 0 {
+		http.Error(w, "目录中没有文件", http.StatusNotFound)
+		return
+	}
+	setTreeArchiveHeaders(w.Header(), format, name, commit)
+	w.WriteHeader(http.StatusOK)
+
+	// 响应头已发出，出错时只能中断连接，客户端会收到不完整的归档
+	if err := writeTreeArchive(ctx, w, format, name, src, commit, files); err != nil {
+		log.Printf("目录打包失败 %s: %v", src.URL.String(), err)
+		panic(http.ErrAbortHandler)
+	}
+	log.Printf("[%s] %s -> 目录归档 %s@%s (%d 个文件)", r.RemoteAddr, r.RequestURI, src.RepoID(), commit, len(files))
+}
+
+// 归档响应头：文件名为 <name>.<格式>，X-Tree-Commit 为解析到的提交
+func setTreeArchiveHeaders(h http.Header, format, name, commit string) {
+	contentType := "application/zip"
+	if format == treeArchiveTarGz {
+		contentType = "application/gzip"
+	}
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + "." + format}))
+	h.Set("X-Tree-Commit", commit)
+}
+
+// 查询错误对应的HTTP状态码
+func treeArchiveErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errTreeTooLarge):
+		return http.StatusForbidden
+	case errors.Is(err, errUpstreamNotFound), errors.Is(err, errRepoNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusBadGateway
+}
+
+// 列出目录在指定提交下的所有文件（包括子目录），不包括子模块
+func listTreeFiles(ctx context.Context, src *SourceURL, commit string) ([]treeFile, error) {
+	var files []treeFile
+	var err error
+	switch src.Platform {
+	case platformGitHub:
+		files, err = listGitHubTree(ctx, src, commit)
+	case platformGitLab:
+		files, err = listGitLabTree(ctx, src, commit)
+	default:
+		err = errUnknownSource
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, f := range files {
+		total += max(f.size, 0)
+	}
+	if total > config.TreeArchive.MaxBytes {
+		return nil, fmt.Errorf("%w: 文件总大小超过 %d 字节", errTreeTooLarge, config.TreeArchive.MaxBytes)
+	}
+	return files, nil
+}
+
+// 文件数是否超过限制
+func checkTreeFileCount(n int) error {
+	if n > config.TreeArchive.MaxFiles {
+		return fmt.Errorf("%w: 文件数超过 %d", errTreeTooLarge, config.TreeArchive.MaxFiles)
+	}
+	return nil
+}
+
+// GitHub tree对象
+type githubTree struct {
+	Tree []struct {
+		Path string `json:"path"`
+		Mode string `json:"mode"`
+		Type string `json:"type"`
+		SHA  string `json:"sha"`
+		Size int64  `json:"size"`
+	} `json:"tree"`
+	Truncated bool `json:"truncated"`
+}
+
+// GitHub：从提交的根目录逐级找到目录对应的tree对象，再递归列出
+func listGitHubTree(ctx context.Context, src *SourceURL, commit string) ([]treeFile, error) {
+	treesAPI := fmt.Sprintf("https://api.github.com/repos/%s/%s/git/trees/",
+		url.PathEscape(src.Namespace), url.PathEscape(src.Repo))
+
+	sha := commit
+	if src.FilePath != "" {
+		for _, name := range strings.Split(src.FilePath, "/") {
+			var tree githubTree
+			if err := fetchUpstreamJSON(ctx, treesAPI+sha, &tree); err != nil {
+				return nil, err
+			}
+			next := ""
+			for _, entry := range tree.Tree {
+				if entry.Path == name && entry.Type == "tree" {
+					next = entry.SHA
+					break
+				}
+			}
+			if next == "" {
+				return nil, fmt.Errorf("%w: 目录 %s 不存在", errUpstreamNotFound, src.FilePath)
+			}
+			sha = next
+		}
+	}
+
+	var tree githubTree
+	if err := fetchUpstreamJSON(ctx, treesAPI+sha+"?recursive=1", &tree); err != nil {
+		return nil, err
+	}
+	if tree.Truncated {
+		return nil, fmt.Errorf("%w: 文件数过多", errTreeTooLarge)
+	}
+	var files []treeFile
+	for _, entry := range tree.Tree {
+		if entry.Type != "blob" {
+			continue
+		}
+		files = append(files, treeFile{path: entry.Path, mode: entry.Mode, size: entry.Size})
+		if err := checkTreeFileCount(len(files)); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// GitLab：递归列出目录，按 Link 响应头翻页
+func listGitLabTree(ctx context.Context, src *SourceURL, commit string) ([]treeFile, error) {
+	apiURL := fmt.Sprintf("https://gitlab.com/api/v4/projects/%s/repository/tree?ref=%s&recursive=true&per_page=100&pagination=keyset",
+		url.PathEscape(src.RepoID()), url.QueryEscape(commit))
+	prefix := ""
+	if src.FilePath != "" {
+		apiURL += "&path=" + url.QueryEscape(src.FilePath)
+		prefix = src.FilePath + "/"
+	}
+
+	var files []treeFile
+	for apiURL != "" {
+		var entries []struct {
+			Path string `json:"path"`
+			Mode string `json:"mode"`
+			Type string `json:"type"`
+		}
+		header, err := fetchUpstreamJSONHeader(ctx, apiURL, &entries)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Type != "blob" || !strings.HasPrefix(entry.Path, prefix) {
+				continue
+			}
+			files = append(files, treeFile{path: strings.TrimPrefix(entry.Path, prefix), mode: entry.Mode, size: -1})
+			if err := checkTreeFileCount(len(files)); err != nil {
+				return nil, err
+			}
+		}
+		if apiURL, err = nextPageURL(header); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// 归档写入器
+type treeArchiveWriter interface {
+	// 写入一个文件，size为-1时大小未知
+	add(name, mode string, size int64, body io.Reader) error
+	Close() error
+}
+
+// 逐个下载文件写入归档，累计大小超过限制时中止
+func writeTreeArchive(ctx context.Context, w io.Writer, format, root string, src *SourceURL, commit string, files []treeFile) error {
+	var archive treeArchiveWriter
+	if format == treeArchiveTarGz {
+		archive = newTarTreeWriter(w)
+	} else {
+		archive = &zipTreeWriter{zw: zip.NewWriter(w), modified: time.Now()}
+	}
+
+	remaining := config.TreeArchive.MaxBytes
+	for _, f := range files {
+		file := *src
+		file.URL = nil
+		file.Kind = SourceFile
+		file.Ref = commit
+		file.FilePath = strings.TrimPrefix(src.FilePath+"/"+f.path, "/")
+
+		n, err := fetchTreeFile(ctx, file.RawURL(), remaining, func(size int64, body io.Reader) error {
+			if f.size < 0 {
+				f.size = size
+			}
+			return archive.add(root+"/"+f.path, f.mode, f.size, body)
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.path, err)
+		}
+		remaining -= n
+	}
+	return archive.Close()
+}
+
+// 下载一个raw文件交给write处理，返回读取的字节数；超过limit时返回 errTreeTooLarge
+func fetchTreeFile(ctx context.Context, rawURL string, limit int64, write func(size int64, body io.Reader) error) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "ghproxy/"+Version)
+	// 不使用传输压缩，Content-Length 即文件大小
+	req.Header.Set("Accept-Encoding", "identity")
+
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("上游返回 %s", resp.Status)
+	}
+	if resp.ContentLength > limit {
+		return 0, fmt.Errorf("%w: 文件总大小超过 %d 字节", errTreeTooLarge, config.TreeArchive.MaxBytes)
+	}
+
+	body := &countingReader{r: io.LimitReader(resp.Body, limit+1)}
+	if err := write(resp.ContentLength, body); err != nil {
+		return body.n, err
+	}
+	if body.n > limit {
+		return body.n, fmt.Errorf("%w: 文件总大小超过 %d 字节", errTreeTooLarge, config.TreeArchive.MaxBytes)
+	}
+	return body.n, nil
+}
+
+// 记录读取字节数的Reader
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// git文件模式对应的文件权限
+func treeFileMode(mode string) os.FileMode {
+	switch mode {
+	case gitModeExecutable:
+		return 0o755
+	case gitModeSymlink:
+		return os.ModeSymlink | 0o777
+	}
+	return 0o644
+}
+
+type zipTreeWriter struct {
+	zw       *zip.Writer
+	modified time.Time
+}
+
+// zip中的符号链接以链接目标为文件内容，并在文件权限中标记
+func (z *zipTreeWriter) add(name, mode string, size int64, body io.Reader) error {
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: z.modified}
+	if mode == gitModeSymlink {
+		header.Method = zip.Store
+	}
+	header.SetMode(treeFileMode(mode))
+	fw, err := z.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, body)
+	return err
+}
+
+func (z *zipTreeWriter) Close() error {
+	return z.zw.Close()
+}
+
+type tarTreeWriter struct {
+	gw       *gzip.Writer
+	tw       *tar.Writer
+	modified time.Time
+}
+
+func newTarTreeWriter(w io.Writer) *tarTreeWriter {
+	gw := gzip.NewWriter(w)
+	return &tarTreeWriter{gw: gw, tw: tar.NewWriter(gw), modified: time.Now()}
+}
+
+// tar需要预先写入文件大小，大小未知时先写入临时文件
+func (t *tarTreeWriter) add(name, mode string, size int64, body io.Reader) error {
+	header := &tar.Header{Name: name, Mode: int64(treeFileMode(mode).Perm()), ModTime: t.modified, Format: tar.FormatPAX}
+	if mode == gitModeSymlink {
+		target, err := io.ReadAll(io.LimitReader(body, maxSymlinkTargetBytes))
+		if err != nil {
+			return err
+		}
+		header.Typeflag = tar.TypeSymlink
+		header.Linkname = string(target)
+		return t.tw.WriteHeader(header)
+	}
+
+	if size < 0 {
+		spool, err := os.CreateTemp("", "ghproxy-tree-")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+		if size, err = io.Copy(spool, body); err != nil {
+			return err
+		}
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		body = spool
+	}
+	header.Typeflag = tar.TypeReg
+	header.Size = size
+	if err := t.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.Copy(t.tw, body)
+	return err
+}
+
+func (t *tarTreeWriter) Close() error {
+	if err := t.tw.Close(); err != nil {
+		return err
+	}
+	return t.gw.Close()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestTarTreeWriterUnknownSize(t *testing.T) {
+	var buf bytes.Buffer
+	archive := newTarTreeWriter(&buf)
+	content := strings.Repeat("ghproxy\n", 1000)
+	if err := archive.add("repo/a.txt", "100644", -1, strings.NewReader(content)); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := archive.add("repo/b.txt", "100644", 5, strings.NewReader("hello")); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	gr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gr)
+	want := map[string]string{"repo/a.txt": content, "repo/b.txt": "hello"}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want[header.Name] || header.Size != int64(len(want[header.Name])) {
+			t.Errorf("%s: size %d, content mismatch", header.Name, header.Size)
+		}
+		delete(want, header.Name)
+	}
+	if len(want) != 0 {
+		t.Errorf("missing entries: %v", want)
+	}
+}
+
+func TestTreeArchiveSource(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want bool
+	}{
+		{"https://github.com/o/r/tree/main/docs?archive=zip", true},
+		{"https://gitlab.com/group/project/-/tree/main/src?archive=tar.gz", true},
+		// 不支持的格式同样由打包处理，返回400
+		{"https://github.com/o/r/tree/main/docs?archive=rar", true},
+		// 不带 archive 参数的目录链接按普通链接代理
+		{"https://github.com/o/r/tree/main/docs", false},
+		{"https://github.com/o/r/tree/main/docs?tab=readme", false},
+		{"https://github.com/o/r/blob/main/a.txt?archive=zip", false},
+		{"https://huggingface.co/org/model/tree/main?archive=zip", false},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.raw)
+		if _, ok := treeArchiveSource(u); ok != tt.want {
+			t.Errorf("treeArchiveSource(%q) = %v, want %v", tt.raw, ok, tt.want)
+		}
+	}
+}
+
+func TestServeTreeArchiveRouting(t *testing.T) {
+	old := repoRefs
+	repoRefs = newRefCache()
+	t.Cleanup(func() { repoRefs = old })
+
+	var requests []string
+	stubUpstream(t, func(r *http.Request) (*http.Response, error) {
+		requests = append(requests, r.Method+" "+r.URL.String())
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/info/refs"):
+			body := pktLine("# service=git-upload-pack\n") + "0000" +
+				pktLine(testCommit+" refs/heads/main\n") + "0000"
+			return stubResponse(r, http.StatusOK, nil, body), nil
+		case r.URL.Host == "api.github.com" && strings.Contains(r.URL.Path, "/commits/"):
+			return stubResponse(r, http.StatusOK, nil, `{"sha":"`+testCommit+`"}`), nil
+		}
+		return stubResponse(r, http.StatusOK, http.Header{"Content-Type": {"text/html"}}, "<html>tree</html>"), nil
+	})
+
+	// HEAD只解析提交，不获取文件列表
+	r := httptest.NewRequest("HEAD", "/https://github.com/o/r/tree/main/docs?archive=tar.gz", nil)
+	w := httptest.NewRecorder()
+	proxyHandler(w, r)
+	h := w.Header()
+	if w.Code != http.StatusOK || h.Get("X-Tree-Commit") != testCommit || h.Get("Content-Type") != "application/gzip" ||
+		h.Get("Content-Disposition") != `attachment; filename=docs.tar.gz` {
+		t.Errorf("HEAD: status %d, headers %v", w.Code, h)
+	}
+	for _, req := range requests {
+		if strings.Contains(req, "/git/trees/") || strings.Contains(req, "/contents/") {
+			t.Errorf("HEAD请求不应获取文件列表: %s", req)
+		}
+	}
+
+	// 不带 archive 参数时原样代理目录页面
+	requests = nil
+	w = httptest.NewRecorder()
+	proxyHandler(w, httptest.NewRequest("GET", "/https://github.com/o/r/tree/main/docs", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "<html>tree</html>" {
+		t.Errorf("普通目录链接: status %d, body %q", w.Code, w.Body.String())
+	}
+	if len(requests) != 1 || requests[0] != "GET https://github.com/o/r/tree/main/docs" {
+		t.Errorf("上游请求 = %q", requests)
+	}
+
+	w = httptest.NewRecorder()
+	proxyHandler(w, httptest.NewRequest("GET", "/https://github.com/o/r/tree/main/docs?archive=rar", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("不支持的格式: status %d", w.Code)
+	}
+}
@@ -0,0 +1,781 @@
+package main
+
+import (
+	"io"
+	"net/http"
+)
+
+// 首页：链接生成界面
+func serveIndex(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, indexHTML)
+}
+
+const indexHTML = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Git文件加速代理</title>
+    <link rel="icon" type="image/x-icon" href="/favicon.ico">
+    <style>
+        * {
+            margin: 0;
+            padding: 0;
+            box-sizing: border-box;
+        }
+        
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif;
+            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
+            min-height: 100vh;
+            color: #333;
+        }
+        
+        .container {
+            max-width: 900px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        
+        .header {
+            text-align: center;
+            color: white;
+            margin-bottom: 40px;
+        }
+        
+        .header h1 {
+            font-size: 2.5rem;
+            margin-bottom: 10px;
+            font-weight: 700;
+        }
+        
+        .header p {
+            font-size: 1.1rem;
+            opacity: 0.9;
+        }
+        
+        .main-panel {
+            background: white;
+            border-radius: 16px;
+            box-shadow: 0 20px 40px rgba(0,0,0,0.1);
+            padding: 40px;
+            margin-bottom: 30px;
+        }
+        
+        .input-section {
+            margin-bottom: 30px;
+        }
+        
+        .input-section label {
+            display: block;
+            margin-bottom: 10px;
+            font-weight: 600;
+            color: #333;
+        }
+        
+        .url-input {
+            width: 100%;
+            padding: 15px 20px;
+            border: 2px solid #e1e5e9;
+            border-radius: 10px;
+            font-size: 16px;
+            transition: all 0.3s ease;
+        }
+        
+        .url-input:focus {
+            outline: none;
+            border-color: #667eea;
+            box-shadow: 0 0 0 3px rgba(102, 126, 234, 0.1);
+        }
+        
+        .generate-btn {
+            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
+            color: white;
+            border: none;
+            padding: 15px 30px;
+            border-radius: 10px;
+            font-size: 16px;
+            font-weight: 600;
+            cursor: pointer;
+            transition: all 0.3s ease;
+            margin-top: 15px;
+            width: 100%;
+        }
+        
+        .generate-btn:hover {
+            transform: translateY(-2px);
+            box-shadow: 0 10px 20px rgba(102, 126, 234, 0.3);
+        }
+        
+        .results {
+            margin-top: 30px;
+        }
+        
+        .result-tabs {
+            display: flex;
+            flex-wrap: wrap;
+            border-bottom: 2px solid #e9ecef;
+            margin-bottom: 20px;
+        }
+        
+        .tab-btn {
+            flex: 1;
+            padding: 12px 16px;
+            background: none;
+            border: none;
+            border-bottom: 3px solid transparent;
+            cursor: pointer;
+            font-size: 14px;
+            font-weight: 500;
+            color: #6c757d;
+            transition: all 0.3s ease;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+            gap: 8px;
+        }
+        
+        .tab-btn:hover {
+            color: #495057;
+            background: #f8f9fa;
+        }
+        
+        .tab-btn.active {
+            color: #667eea;
+            border-bottom-color: #667eea;
+            background: #f8f9fa;
+        }
+        
+        .result-item {
+            background: #f8f9fa;
+            border: 1px solid #e9ecef;
+            border-radius: 10px;
+            padding: 20px;
+        }
+        
+        .result-item h3 {
+            color: #495057;
+            margin-bottom: 10px;
+            font-size: 1.1rem;
+        }
+        
+        .result-code {
+            background: #f1f3f4;
+            border: 1px solid #dadce0;
+            border-radius: 6px;
+            padding: 12px;
+            font-family: 'Monaco', 'Menlo', 'Ubuntu Mono', monospace;
+            font-size: 14px;
+            word-break: break-all;
+            position: relative;
+            min-height: 20px;
+        }
+        
+        .result-code span {
+            display: block;
+            min-height: 20px;
+        }
+        
+        .result-code span:not(:empty) {
+            padding-right: 80px;
+        }
+        
+        .copy-btn {
+            position: absolute;
+            top: 10px;
+            right: 10px;
+            background: #667eea;
+            color: white;
+            border: none;
+            padding: 5px 10px;
+            border-radius: 4px;
+            font-size: 12px;
+            cursor: pointer;
+            transition: background 0.3s ease;
+            opacity: 0;
+            visibility: hidden;
+        }
+        
+        .result-code span:not(:empty) + .copy-btn {
+            opacity: 1;
+            visibility: visible;
+        }
+        
+        .copy-btn:hover {
+            background: #5a6fd8;
+        }
+        
+        .rewrite-text {
+            width: 100%;
+            min-height: 160px;
+            padding: 15px 20px;
+            border: 2px solid #e1e5e9;
+            border-radius: 10px;
+            font-family: 'Monaco', 'Menlo', 'Ubuntu Mono', monospace;
+            font-size: 13px;
+            resize: vertical;
+        }
+        
+        .rewrite-actions {
+            display: flex;
+            align-items: center;
+            gap: 15px;
+            margin: 15px 0;
+        }
+        
+        .rewrite-btn {
+            background: #667eea;
+            color: white;
+            border: none;
+            padding: 10px 20px;
+            border-radius: 8px;
+            font-size: 14px;
+            cursor: pointer;
+        }
+        
+        .setup-code {
+            margin-bottom: 10px;
+        }
+        
+        .rewrite-summary {
+            color: #6c757d;
+            font-size: 0.9rem;
+        }
+        
+        .platforms {
+            background: white;
+            border-radius: 16px;
+            box-shadow: 0 20px 40px rgba(0,0,0,0.1);
+            padding: 30px;
+        }
+        
+        .platforms h2 {
+            text-align: center;
+            color: #333;
+            margin-bottom: 20px;
+        }
+        
+        .platform-grid {
+            display: grid;
+            grid-template-columns: repeat(auto-fit, minmax(200px, 1fr));
+            gap: 20px;
+        }
+        
+        .platform-card {
+            background: #f8f9fa;
+            border-radius: 10px;
+            padding: 20px;
+            text-align: center;
+        }
+        
+        .platform-card h3 {
+            color: #495057;
+            margin-bottom: 10px;
+        }
+        
+        .platform-card p {
+            color: #6c757d;
+            font-size: 0.9rem;
+        }
+        
+        .features {
+            background: white;
+            border-radius: 16px;
+            box-shadow: 0 20px 40px rgba(0,0,0,0.1);
+            padding: 30px;
+            margin-bottom: 30px;
+        }
+        
+        .features h2 {
+            text-align: center;
+            color: #333;
+            margin-bottom: 20px;
+        }
+        
+        .feature-list {
+            display: grid;
+            grid-template-columns: repeat(auto-fit, minmax(250px, 1fr));
+            gap: 20px;
+        }
+        
+        .feature-item {
+            background: #f8f9fa;
+            border-radius: 10px;
+            padding: 20px;
+        }
+        
+        .feature-item h3 {
+            color: #495057;
+            margin-bottom: 10px;
+            font-size: 1.1rem;
+        }
+        
+        .feature-item p {
+            color: #6c757d;
+            font-size: 0.9rem;
+            line-height: 1.5;
+        }
+        
+        .toast {
+            position: fixed;
+            top: 20px;
+            right: 20px;
+            background: #28a745;
+            color: white;
+            padding: 15px 20px;
+            border-radius: 8px;
+            display: none;
+            z-index: 1000;
+        }
+        
+        @media (max-width: 768px) {
+            .container {
+                padding: 15px;
+            }
+            
+            .main-panel {
+                padding: 25px;
+            }
+            
+            .header h1 {
+                font-size: 2rem;
+            }
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>🚀 Git文件加速代理</h1>
+            <p>支持 GitHub、GitLab、Hugging Face 三大平台文件加速访问</p>
+        </div>
+        
+        <div class="main-panel">
+            <div class="input-section">
+                <label for="original-url">输入原始链接：</label>
+                <input type="text" id="original-url" class="url-input" 
+                       placeholder="例如：https://github.com/user/repo/blob/main/file.txt"
+                       oninput="generateLinksRealtime()">
+            </div>
+            
+            <div id="results" class="results">
+                <div class="result-tabs" id="result-tabs">
+                    <button class="tab-btn active" onclick="switchTab('browser')">
+                        <span>🌐</span> 浏览器访问
+                    </button>
+                    <button class="tab-btn" onclick="switchTab('wget')">
+                        <span>📥</span> wget 下载
+                    </button>
+                    <button class="tab-btn" onclick="switchTab('curl')">
+                        <span>📦</span> curl 下载
+                    </button>
+                    <button class="tab-btn" onclick="switchTab('git')">
+                        <span>🔀</span> git clone
+                    </button>
+                    <button class="tab-btn" onclick="switchTab('pin')">
+                        <span>📌</span> 固定版本
+                    </button>
+                </div>
+                
+                <div class="result-item">
+                    <div class="result-code">
+                        <span id="result-content"></span>
+                        <button class="copy-btn" onclick="copyResult()">复制</button>
+                    </div>
+                </div>
+            </div>
+        </div>
+        
+        <div class="main-panel">
+            <div class="input-section">
+                <label for="rewrite-input">文本改写：粘贴README、Dockerfile或脚本，其中的链接会全部改写为加速链接</label>
+                <textarea id="rewrite-input" class="rewrite-text" placeholder="RUN curl -fsSL https://raw.githubusercontent.com/user/repo/main/install.sh | sh"></textarea>
+            </div>
+            <div class="rewrite-actions">
+                <button class="rewrite-btn" onclick="rewriteText()">改写</button>
+                <button class="rewrite-btn" onclick="copyRewritten()">复制结果</button>
+                <span id="rewrite-summary" class="rewrite-summary"></span>
+            </div>
+            <textarea id="rewrite-output" class="rewrite-text" readonly></textarea>
+        </div>
+        
+        <div class="main-panel">
+            <div class="result-item">
+                <h3>⚡ 持久加速：在开发机上执行一次，自动配置git加速、HF_ENDPOINT和 ghget 下载命令</h3>
+                <div class="result-code setup-code">
+                    <span id="setup-sh"></span>
+                    <button class="copy-btn" onclick="copySetup('setup-sh')">复制</button>
+                </div>
+                <div class="result-code setup-code">
+                    <span id="setup-ps1"></span>
+                    <button class="copy-btn" onclick="copySetup('setup-ps1')">复制</button>
+                </div>
+            </div>
+        </div>
+        
+        <div class="platforms">
+            <h2>支持的平台</h2>
+            <div class="platform-grid">
+                <div class="platform-card">
+                    <h3>GitHub</h3>
+                    <p>支持仓库文件、Raw文件、Gist等</p>
+                </div>
+                <div class="platform-card">
+                    <h3>GitLab</h3>
+                    <p>支持项目文件和Raw文件</p>
+                </div>
+                <div class="platform-card">
+                    <h3>Hugging Face</h3>
+                    <p>支持模型和数据集文件</p>
+                </div>
+            </div>
+        </div>
+    </div>
+    
+    <div id="toast" class="toast">复制成功！</div>
+    
+    <script>
+        // 存储所有生成的链接
+        let generatedLinks = {
+            browser: '',
+            wget: '',
+            curl: '',
+            git: '',
+            pin: ''
+        };
+        
+        // 当前活跃的标签
+        let currentTab = 'browser';
+        
+        function switchTab(tabName) {
+            // 更新标签按钮状态
+            document.querySelectorAll('.tab-btn').forEach(btn => {
+                btn.classList.remove('active');
+            });
+            event.target.closest('.tab-btn').classList.add('active');
+            
+            // 更新当前标签
+            currentTab = tabName;
+            
+            // 固定版本需要查询上游，切换到该标签时才请求
+            if (tabName === 'pin') {
+                requestPin();
+            }
+            
+            // 更新显示内容
+            updateResultContent();
+        }
+        
+        // 已解析固定版本的链接，避免重复请求
+        let pinnedFor = '';
+        
+        function requestPin() {
+            const originalUrl = document.getElementById('original-url').value.trim();
+            if (!originalUrl || pinnedFor === originalUrl || !generatedLinks.browser) {
+                return;
+            }
+            pinnedFor = originalUrl;
+            generatedLinks.pin = '正在解析提交...';
+            
+            fetch('/api/pin?url=' + encodeURIComponent(originalUrl))
+                .then(resp => resp.json())
+                .then(data => {
+                    if (pinnedFor !== originalUrl) {
+                        return;
+                    }
+                    generatedLinks.pin = data.success ? data.pinned_url : data.error;
+                    updateResultContent();
+                })
+                .catch(() => {
+                    if (pinnedFor === originalUrl) {
+                        generatedLinks.pin = '解析提交失败，请稍后重试';
+                        pinnedFor = '';
+                        updateResultContent();
+                    }
+                });
+        }
+        
+        function updateResultContent() {
+            const resultContent = document.getElementById('result-content');
+            resultContent.textContent = generatedLinks[currentTab] || '';
+        }
+        
+        // 请求序号，丢弃过期的响应
+        let generateSeq = 0;
+        let generateTimer = null;
+        
+        function generateLinksRealtime() {
+            // 输入时稍作延迟，避免每个字符都请求一次
+            clearTimeout(generateTimer);
+            generateTimer = setTimeout(requestLinks, 200);
+        }
+        
+        function showError(message) {
+            Object.keys(generatedLinks).forEach(key => {
+                generatedLinks[key] = message;
+            });
+            updateResultContent();
+        }
+        
+        // 链接的解析和校验由服务端完成，与代理使用同一套规则
+        function requestLinks() {
+            const originalUrl = document.getElementById('original-url').value.trim();
+            const seq = ++generateSeq;
+            
+            // 清空所有链接
+            generatedLinks = {
+                browser: '',
+                wget: '',
+                curl: '',
+                git: '',
+                pin: ''
+            };
+            
+            // 如果输入为空，清空显示
+            if (!originalUrl) {
+                updateResultContent();
+                return;
+            }
+            
+            fetch('/api/generate', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ original_url: originalUrl })
+            })
+                .then(resp => resp.json())
+                .then(data => {
+                    if (seq !== generateSeq) {
+                        return;
+                    }
+                    if (!data.success) {
+                        showError(data.error);
+                        return;
+                    }
+                    
+                    // 存储各种格式的链接
+                    generatedLinks.browser = data.short_link || data.browser_link;
+                    generatedLinks.wget = data.wget_command;
+                    generatedLinks.curl = data.curl_command;
+                    generatedLinks.git = data.git_command;
+                    extraCommands.forEach(cmd => {
+                        const commands = data.commands || {};
+                        generatedLinks['cmd:' + cmd.name] = commands[cmd.name] || '此链接不支持该命令';
+                    });
+                    pinnedFor = '';
+                    if (currentTab === 'pin') {
+                        requestPin();
+                    }
+                    
+                    // 更新当前显示的内容
+                    updateResultContent();
+                })
+                .catch(() => {
+                    if (seq === generateSeq) {
+                        showError('生成链接失败，请稍后重试');
+                    }
+                });
+        }
+        
+        function generateLinks() {
+            // 保持兼容性，直接调用实时生成函数
+            generateLinksRealtime();
+            
+            // 滚动到结果区域
+            document.getElementById('results').scrollIntoView({ behavior: 'smooth' });
+        }
+        
+        function copyResult() {
+            const text = generatedLinks[currentTab];
+            
+            navigator.clipboard.writeText(text).then(function() {
+                showToast();
+            }).catch(function(err) {
+                // 降级方案
+                const textArea = document.createElement('textarea');
+                textArea.value = text;
+                document.body.appendChild(textArea);
+                textArea.select();
+                document.execCommand('copy');
+                document.body.removeChild(textArea);
+                showToast();
+            });
+        }
+        
+        function showToast() {
+            const toast = document.getElementById('toast');
+            toast.style.display = 'block';
+            setTimeout(function() {
+                toast.style.display = 'none';
+            }, 2000);
+        }
+        
+        // 文本改写：由服务端按代理的规则改写所有支持的链接
+        function rewriteText() {
+            const text = document.getElementById('rewrite-input').value;
+            const summary = document.getElementById('rewrite-summary');
+            
+            fetch('/api/rewrite', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ text: text })
+            })
+                .then(resp => resp.json())
+                .then(data => {
+                    if (!data.success) {
+                        summary.textContent = data.error;
+                        return;
+                    }
+                    document.getElementById('rewrite-output').value = data.text;
+                    summary.textContent = '已改写 ' + data.changed.length + ' 个链接，跳过 ' + data.skipped.length + ' 个';
+                })
+                .catch(() => {
+                    summary.textContent = '改写失败，请稍后重试';
+                });
+        }
+        
+        function copyRewritten() {
+            const output = document.getElementById('rewrite-output');
+            if (!output.value) {
+                return;
+            }
+            output.select();
+            navigator.clipboard.writeText(output.value).then(showToast).catch(function() {
+                document.execCommand('copy');
+                showToast();
+            });
+        }
+        
+        // 客户端配置脚本的执行命令
+        function showSetupCommands() {
+            document.getElementById('setup-sh').textContent = 'curl -fsSL ' + location.origin + '/setup.sh | sh';
+            document.getElementById('setup-ps1').textContent = 'irm ' + location.origin + '/setup.ps1 | iex';
+        }
+        
+        function copySetup(id) {
+            const text = document.getElementById(id).textContent;
+            navigator.clipboard.writeText(text).then(showToast).catch(function() {
+                const textArea = document.createElement('textarea');
+                textArea.value = text;
+                document.body.appendChild(textArea);
+                textArea.select();
+                document.execCommand('copy');
+                document.body.removeChild(textArea);
+                showToast();
+            });
+        }
+        
+        // 服务端配置的命令模板，每个模板对应一个标签
+        let extraCommands = [];
+        
+        function loadCommandTabs() {
+            fetch('/api/commands')
+                .then(resp => resp.json())
+                .then(list => {
+                    extraCommands = list;
+                    const tabs = document.getElementById('result-tabs');
+                    list.forEach(cmd => {
+                        const btn = document.createElement('button');
+                        btn.className = 'tab-btn';
+                        btn.textContent = cmd.label;
+                        btn.addEventListener('click', () => switchTab('cmd:' + cmd.name));
+                        tabs.appendChild(btn);
+                    });
+                    // 已有输入时重新生成，补上新标签的内容
+                    generateLinksRealtime();
+                })
+                .catch(() => {});
+        }
+        
+        // 页面加载时的示例
+        window.addEventListener('load', function() {
+            loadCommandTabs();
+            showSetupCommands();
+            
+            // 可以在这里添加示例链接
+            const examples = [
+                'https://github.com/vansour/bbr/blob/main/bbr.sh',
+                'https://gitlab.com/gitlab-org/gitlab/-/blob/master/README.md',
+                'https://huggingface.co/microsoft/DialoGPT-medium/resolve/main/README.md'
+            ];
+            
+            // 随机显示一个示例
+            const randomExample = examples[Math.floor(Math.random() * examples.length)];
+            document.getElementById('original-url').placeholder = '例如：' + randomExample;
+        });
+    </script>
+    
+    <!-- 页脚 -->
+    <footer class="footer">
+        <div class="footer-content">
+            <p>&copy; 2024-2025 Git文件加速代理 | 
+                <a href="https://github.com/vansour/ghproxy" target="_blank" rel="noopener noreferrer">
+                    <span>📦</span> GitHub仓库
+                </a> | 
+                <a href="https://hub.docker.com/r/vansour/ghproxy" target="_blank" rel="noopener noreferrer">
+                    <span>🐳</span> Docker镜像
+                </a>
+            </p>
+        </div>
+    </footer>
+    
+    <style>
+        .footer {
+            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
+            color: white;
+            padding: 20px 0;
+            margin-top: 40px;
+            text-align: center;
+        }
+        
+        .footer-content {
+            max-width: 900px;
+            margin: 0 auto;
+            padding: 0 20px;
+        }
+        
+        .footer p {
+            margin: 0;
+            font-size: 0.9rem;
+            opacity: 0.9;
+        }
+        
+        .footer a {
+            color: white;
+            text-decoration: none;
+            margin: 0 10px;
+            transition: all 0.3s ease;
+            display: inline-flex;
+            align-items: center;
+            gap: 5px;
+        }
+        
+        .footer a:hover {
+            opacity: 0.8;
+            transform: translateY(-1px);
+        }
+        
+        .footer a span {
+            font-size: 1rem;
+        }
+        
+        @media (max-width: 768px) {
+            .footer {
+                padding: 15px 0;
+            }
+            
+            .footer p {
+                font-size: 0.8rem;
+                line-height: 1.6;
+            }
+            
+            .footer a {
+                margin: 0 5px;
+                font-size: 0.8rem;
+            }
+        }
+    </style>
+</body>
+</html>
+`
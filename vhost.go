@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 按入站主机名查找虚拟主机对应的上游主机
+func vhostUpstream(host string) (string, bool) {
+	if len(config.VHosts) == 0 {
+		return "", false
+	}
+	upstream, ok := config.VHosts[normalizeHost(host)]
+	return upstream, ok
+}
+
+// 按上游主机查找对应的虚拟主机，多个虚拟主机对应同一上游时取名称最小的
+func vhostFor(upstream string) (string, bool) {
+	found := ""
+	for vhost, target := range config.VHosts {
+		if target == upstream && (found == "" || vhost < found) {
+			found = vhost
+		}
+	}
+	return found, found != ""
+}
+
+// 检查虚拟主机配置：上游必须是已启用平台的域名；主机名统一规范化
+func validateVHosts(cfg *Config) error {
+	if len(cfg.VHosts) == 0 {
+		return nil
+	}
+	allowed := make(map[string]bool)
+	for _, p := range cfg.Platforms {
+		for _, domain := range platformDomains[p] {
+			allowed[domain] = true
+		}
+	}
+	vhosts := make(map[string]string, len(cfg.VHosts))
+	for vhost, upstream := range cfg.VHosts {
+		vhost, upstream = normalizeHost(vhost), normalizeHost(upstream)
+		if vhost == "" {
+			return fmt.Errorf("虚拟主机名不能为空")
+		}
+		if !allowed[upstream] {
+			return fmt.Errorf("虚拟主机 %s 的上游 %q 不是已启用平台的域名", vhost, upstream)
+		}
+		vhosts[vhost] = upstream
+	}
+	cfg.VHosts = vhosts
+	return nil
+}
+
+// 虚拟主机模式：按Host把请求原样转发到对应的上游，如 raw.gh.example.com/o/r/main/x -> raw.githubusercontent.com/o/r/main/x
+func vhostHandler(w http.ResponseWriter, r *http.Request, upstream string) {
+	log.Printf("收到虚拟主机请求: %s%s -> %s", r.Host, r.RequestURI, upstream)
+
+	if !strings.HasPrefix(r.RequestURI, "/") {
+		http.Error(w, "无效的请求路径", http.StatusBadRequest)
+		return
+	}
+	targetURL, err := extractTargetURL("https://" + upstream + r.RequestURI)
+	if err != nil {
+		http.Error(w, "URL解析失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 虚拟主机模式下请求路径即上游路径，不支持脚本改写
+	serveProxy(w, r, targetURL, false)
+}
+
+// 把指向上游的地址改写为对应虚拟主机的地址，沿用请求的协议和端口；没有对应的虚拟主机时返回false
+func vhostURL(r *http.Request, target *url.URL) (string, bool) {
+	vhost, ok := vhostFor(target.Host)
+	if !ok {
+		return "", false
+	}
+	if _, port, err := net.SplitHostPort(r.Host); err == nil {
+		vhost = net.JoinHostPort(vhost, port)
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + vhost + target.RequestURI(), true
+}
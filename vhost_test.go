@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 测试用的虚拟主机映射
+func withVHosts(t *testing.T) {
+	t.Helper()
+	withConfig(t, func(cfg *Config) {
+		cfg.VHosts = map[string]string{
+			"gh.example.com":     "github.com",
+			"raw.gh.example.com": "raw.githubusercontent.com",
+		}
+	})
+}
+
+func TestVHostUpstream(t *testing.T) {
+	withVHosts(t)
+	tests := []struct {
+		host     string
+		upstream string
+		ok       bool
+	}{
+		{"raw.gh.example.com", "raw.githubusercontent.com", true},
+		// 带端口、大小写和结尾的点都能匹配
+		{"raw.gh.example.com:8080", "raw.githubusercontent.com", true},
+		{"GH.Example.COM.", "github.com", true},
+		{"proxy.example.com", "", false},
+		{"example.com", "", false},
+	}
+	for _, tt := range tests {
+		upstream, ok := vhostUpstream(tt.host)
+		if upstream != tt.upstream || ok != tt.ok {
+			t.Errorf("vhostUpstream(%q) = %q, %v; want %q, %v", tt.host, upstream, ok, tt.upstream, tt.ok)
+		}
+	}
+}
+
+func TestRouteRequestVHost(t *testing.T) {
+	withVHosts(t)
+	var upstreamURL string
+	stubUpstream(t, func(r *http.Request) (*http.Response, error) {
+		upstreamURL = r.URL.String()
+		return stubResponse(r, http.StatusOK, nil, "ok"), nil
+	})
+
+	tests := []struct {
+		host, path string
+		want       string
+	}{
+		// 虚拟主机：路径原样转发到对应上游
+		{"raw.gh.example.com:8080", "/o/r/main/a%20b.txt?x=1", "https://raw.githubusercontent.com/o/r/main/a%20b.txt?x=1"},
+		// 未配置的Host走普通的路径前缀代理
+		{"proxy.example.com", "/https://raw.githubusercontent.com/o/r/main/a.txt", "https://raw.githubusercontent.com/o/r/main/a.txt"},
+	}
+	for _, tt := range tests {
+		upstreamURL = ""
+		r := httptest.NewRequest("GET", tt.path, nil)
+		r.Host = tt.host
+		w := httptest.NewRecorder()
+		routeRequest(w, r)
+		if w.Code != http.StatusOK || upstreamURL != tt.want {
+			t.Errorf("%s%s: status %d, 上游 %q; want %q", tt.host, tt.path, w.Code, upstreamURL, tt.want)
+		}
+	}
+
+	// 未配置的Host上，接口路由不受虚拟主机影响
+	r := httptest.NewRequest("GET", "/api/gitconfig?platforms=github", nil)
+	r.Host = "proxy.example.com"
+	w := httptest.NewRecorder()
+	routeRequest(w, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "url.http://proxy.example.com/https://github.com/.insteadOf") {
+		t.Errorf("/api/gitconfig: status %d, body %q", w.Code, w.Body.String())
+	}
+}
+
+func TestRewriteLocationVHost(t *testing.T) {
+	withVHosts(t)
+	tests := []struct {
+		host     string
+		tls      bool
+		location string
+		want     string
+	}{
+		// 指向有虚拟主机的上游时改写为虚拟主机地址，沿用请求的协议和端口
+		{"gh.example.com:8080", false, "https://raw.githubusercontent.com/o/r/main/a.txt", "http://raw.gh.example.com:8080/o/r/main/a.txt"},
+		{"gh.example.com", true, "https://raw.githubusercontent.com/o/r/main/a.txt?token=x", "https://raw.gh.example.com/o/r/main/a.txt?token=x"},
+		// 没有对应虚拟主机的上游保持不变
+		{"gh.example.com", false, "https://objects.githubusercontent.com/asset", "https://objects.githubusercontent.com/asset"},
+	}
+	for _, tt := range tests {
+		target := "/o/r/raw/main/a.txt"
+		if tt.tls {
+			// https:// 开头的目标会让 httptest 设置 r.TLS
+			target = "https://" + tt.host + target
+		}
+		r := httptest.NewRequest("GET", target, nil)
+		r.Host = tt.host
+		upstreamReq := httptest.NewRequest("GET", "https://github.com/o/r/raw/main/a.txt", nil)
+		resp := stubResponse(upstreamReq, http.StatusFound, http.Header{"Location": {tt.location}}, "")
+		rewriteLocation(r, resp)
+		if got := resp.Header.Get("Location"); got != tt.want {
+			t.Errorf("%s: rewriteLocation(%q) = %q, want %q", tt.host, tt.location, got, tt.want)
+		}
+	}
+}
+
+func TestValidateVHostsConfig(t *testing.T) {
+	cfg, err := loadTestConfig(t, `{"vhosts": {"RAW.GH.Example.com:443": "Raw.GitHubUserContent.com."}}`)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if len(cfg.VHosts) != 1 || cfg.VHosts["raw.gh.example.com"] != "raw.githubusercontent.com" {
+		t.Errorf("主机名应被规范化: %v", cfg.VHosts)
+	}
+
+	for _, content := range []string{
+		`{"vhosts": {"gh.example.com": "example.com"}}`,
+		`{"platforms": ["github"], "vhosts": {"hf.example.com": "huggingface.co"}}`,
+		`{"vhosts": {"": "github.com"}}`,
+	} {
+		if _, err := loadTestConfig(t, content); err == nil {
+			t.Errorf("%s: 应返回错误", content)
+		}
+	}
+}